@@ -0,0 +1,265 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"bubble-chess/engine"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/notnil/chess"
+)
+
+const autosaveName = "autosave.json"
+
+// savedGame is what is written after every move so that a game can be
+// picked up again after the program exits.
+type savedGame struct {
+	PGN        string      `json:"pgn"`
+	Opponent   string      `json:"opponent"`
+	CPUColor   string      `json:"cpuColor,omitempty"`
+	Difficulty int         `json:"difficulty"`
+	AutoFlip   bool        `json:"autoFlip,omitempty"`
+	StartedAt  time.Time   `json:"startedAt"`
+	Clock      *savedClock `json:"clock,omitempty"`
+}
+
+// savedClock is the state of the clocks between moves.
+type savedClock struct {
+	Control string    `json:"control"`
+	White   savedTime `json:"white"`
+	Black   savedTime `json:"black"`
+}
+
+type savedTime struct {
+	Remaining   time.Duration `json:"remaining"`
+	Period      int           `json:"period,omitempty"`
+	PeriodMoves int           `json:"periodMoves,omitempty"`
+}
+
+// stateDir returns the directory bubble-chess keeps its state in,
+// following the XDG base directory spec.
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "bubble-chess"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "bubble-chess"), nil
+}
+
+// configDir returns the directory bubble-chess reads its configuration
+// from, following the XDG base directory spec.
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "bubble-chess"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "bubble-chess"), nil
+}
+
+func autosavePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, autosaveName), nil
+}
+
+func hasAutosave() bool {
+	path, err := autosavePath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// autosave writes the game in progress to the state directory.
+func (m *Model) autosave() error {
+	path, err := autosavePath()
+	if err != nil {
+		return err
+	}
+	saved := savedGame{
+		PGN:        m.pgn(),
+		Opponent:   "player",
+		Difficulty: m.difficulty,
+		AutoFlip:   m.autoFlip,
+		StartedAt:  m.startedAt,
+	}
+	if m.clock != nil {
+		now := time.Now()
+		save := func(color chess.Color) savedTime {
+			return savedTime{
+				Remaining:   m.clock.left(color, now),
+				Period:      m.clock.period[color],
+				PeriodMoves: m.clock.periodMoves[color],
+			}
+		}
+		saved.Clock = &savedClock{
+			Control: m.clock.control.spec,
+			White:   save(chess.White),
+			Black:   save(chess.Black),
+		}
+	}
+	if m.opponent == ComputerOpponent {
+		saved.Opponent = "computer"
+		saved.CPUColor = m.cpuColor.String()
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to the side and rename so that quitting mid-write cannot leave
+	// half a save behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	m.refreshMainMenu()
+	return nil
+}
+
+// saveProgress autosaves after a move, leaving any error for the player to
+// see without holding up the game.
+func (m *Model) saveProgress() {
+	if err := m.autosave(); err != nil && m.err == nil {
+		m.err = fmt.Errorf("Could not autosave: %w", err)
+	}
+}
+
+// clearAutosave removes the save once its game is over.
+func (m *Model) clearAutosave() {
+	if path, err := autosavePath(); err == nil {
+		os.Remove(path)
+	}
+	m.refreshMainMenu()
+}
+
+func loadAutosave() (savedGame, error) {
+	var saved savedGame
+	path, err := autosavePath()
+	if err != nil {
+		return saved, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return saved, err
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return saved, errDamagedSave
+	}
+	return saved, nil
+}
+
+var errDamagedSave = errors.New("The saved game was damaged and has been discarded")
+
+// resumeGame restores the autosaved game and carries on where it left off.
+func (m *Model) resumeGame() tea.Cmd {
+	saved, err := loadAutosave()
+	var game *chess.Game
+	if err == nil {
+		if game, err = decodePGN(saved.PGN); err != nil {
+			err = errDamagedSave
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errDamagedSave) {
+			m.clearAutosave()
+		}
+		m.err = err
+		return nil
+	}
+	if saved.Difficulty < 0 || saved.Difficulty >= len(engine.Levels) {
+		saved.Difficulty = defaultDifficulty
+	}
+
+	opp := opponent(HumanOpponent)
+	if saved.Opponent == "computer" {
+		opp = ComputerOpponent
+	}
+	m.difficulty = saved.Difficulty
+	m.difficultyCursor = saved.Difficulty
+	m.cpu.SetLevel(engine.Levels[m.difficulty])
+	m.newGame(opp)
+	// The saved game keeps its own starting position, while new games
+	// still start from the one the player chose.
+	m.gameFEN = ""
+	if fen := game.GetTagPair("FEN"); fen != nil {
+		m.gameFEN = fen.Value
+	}
+	m.startedAt = saved.StartedAt
+	m.autoFlip = saved.AutoFlip
+	if saved.CPUColor == chess.White.String() {
+		m.cpuColor = chess.White
+		m.boardDirection = BlackDirection
+	} else {
+		m.cpuColor = chess.Black
+	}
+	m.replayGame(game.Moves())
+	if len(m.game.Moves()) != len(game.Moves()) {
+		m.err = errors.New("Part of the saved game could not be replayed")
+	}
+	m.refreshGuesses()
+	m.afterHumanMove()
+	m.mode = GameMode
+	return tea.Batch(m.restoreClock(saved.Clock), m.gameNextStep)
+}
+
+// restoreClock sets the clocks back to where they were when the game was
+// saved. The time the program was closed for is not counted.
+func (m *Model) restoreClock(saved *savedClock) tea.Cmd {
+	m.flagged = chess.NoColor
+	m.clock = nil
+	m.timeControl = ""
+	if saved == nil {
+		return nil
+	}
+	tc, err := parseTimeControl(saved.Control)
+	if err != nil || len(tc.periods) == 0 {
+		return nil
+	}
+	m.timeControl = tc.pgnTag()
+	m.clock = newClock(tc)
+	for color, t := range map[chess.Color]savedTime{chess.White: saved.White, chess.Black: saved.Black} {
+		if t.Period < 0 || t.Period >= len(tc.periods) {
+			t.Period, t.PeriodMoves = 0, 0
+		}
+		m.clock.remaining[color] = t.Remaining
+		m.clock.period[color] = t.Period
+		m.clock.periodMoves[color] = t.PeriodMoves
+	}
+	return m.resumeClock()
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestResumeKeepsStartFEN(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	const chosen = "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+	const saved = "4k3/4p3/8/8/8/8/8/4K3 w - - 0 1"
+
+	m := New(saved)
+	m.newGame(HumanOpponent)
+	if err := m.game.MoveStr("Kd2"); err != nil {
+		t.Fatal(err)
+	}
+	m.saveProgress()
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+
+	m = New(chosen)
+	m.resumeGame()
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if got := m.game.Positions()[0].String(); got != saved {
+		t.Errorf("resumed game starts from %q, want %q", got, saved)
+	}
+	if len(m.game.Moves()) != 1 {
+		t.Errorf("resumed game has %d moves, want 1", len(m.game.Moves()))
+	}
+	recorded := false
+	for _, tag := range m.pgnTags() {
+		recorded = recorded || tag == tagPair{"FEN", saved}
+	}
+	if !recorded {
+		t.Errorf("resumed game's PGN tags %v do not record its starting position", m.pgnTags())
+	}
+	if m.startFEN != chosen {
+		t.Errorf("startFEN = %q after resuming, want %q", m.startFEN, chosen)
+	}
+
+	m.newGame(HumanOpponent)
+	if got := m.game.Position().String(); got != chosen {
+		t.Errorf("new game starts from %q, want %q", got, chosen)
+	}
+
+	m.startFEN = ""
+	m.newGame(HumanOpponent)
+	if got, want := m.game.Position().String(), chess.StartingPosition().String(); got != want {
+		t.Errorf("new game starts from %q, want %q", got, want)
+	}
+}
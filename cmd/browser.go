@@ -0,0 +1,445 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+// pgnEntry is what the browser keeps of each game in a PGN file: the tags
+// it shows and filters on, and where to find the rest when it is opened.
+type pgnEntry struct {
+	offset int64
+	length int
+	tags   map[string]string
+}
+
+type browserColumn struct {
+	title string
+	tag   string
+	width int
+}
+
+// fenMatchMsg carries the games of a PGN file that reach a position.
+type fenMatchMsg struct {
+	fen     string
+	matches map[int]bool
+	err     error
+}
+
+// fenSearchMsg starts a position search once typing has paused on fen.
+type fenSearchMsg struct {
+	fen string
+}
+
+// pgnFilter is a parsed browser query. Bare words match either player,
+// the rest are key:value pairs, and fen: takes the rest of the line.
+type pgnFilter struct {
+	players []string
+	white   string
+	black   string
+	result  string
+	eco     string
+	opening string
+	fen     string
+}
+
+// fenSearchDelay is how long typing must pause before a position search
+// starts, since each one reads the whole file.
+const fenSearchDelay = 300 * time.Millisecond
+
+var indexTags = []string{"Event", "Site", "Date", "White", "Black", "Result", "ECO", "Opening"}
+
+var browserColumns = []browserColumn{
+	{title: "#", width: 5},
+	{title: "White", tag: "White", width: 18},
+	{title: "Black", tag: "Black", width: 18},
+	{title: "Result", tag: "Result", width: 7},
+	{title: "Date", tag: "Date", width: 10},
+	{title: "ECO", tag: "ECO", width: 3},
+}
+
+var resultAliases = map[string]string{
+	"white": "1-0",
+	"black": "0-1",
+	"draw":  "1/2-1/2",
+	"1/2":   "1/2-1/2",
+	"½":     "1/2-1/2",
+}
+
+func (e pgnEntry) tag(key string) string {
+	if v, ok := e.tags[key]; ok && v != "" {
+		return v
+	}
+	return "?"
+}
+
+// indexPGN reads the tags of every game in the file at path without
+// keeping the games themselves.
+func indexPGN(path string) ([]pgnEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []pgnEntry
+	err = scanPGN(f, func(offset int64, text string) error {
+		all := readTags(text)
+		tags := make(map[string]string, len(indexTags))
+		for _, key := range indexTags {
+			if v, ok := all[key]; ok {
+				tags[key] = v
+			}
+		}
+		entries = append(entries, pgnEntry{offset: offset, length: len(text), tags: tags})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s has no games in it", path)
+	}
+	return entries, nil
+}
+
+func readPGNEntry(path string, entry pgnEntry) (*chess.Game, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, entry.length)
+	if _, err := f.ReadAt(buf, entry.offset); err != nil {
+		return nil, err
+	}
+	return decodePGN(string(buf))
+}
+
+func parseFilter(query string) pgnFilter {
+	var f pgnFilter
+	fields := strings.Fields(query)
+	for i, field := range fields {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok {
+			f.players = append(f.players, strings.ToLower(field))
+			continue
+		}
+		if strings.EqualFold(key, "fen") {
+			f.fen = positionKey(strings.Join(append([]string{value}, fields[i+1:]...), " "))
+			return f
+		}
+		value = strings.ToLower(value)
+		switch strings.ToLower(key) {
+		case "white", "w":
+			f.white = value
+		case "black", "b":
+			f.black = value
+		case "player", "p":
+			f.players = append(f.players, value)
+		case "result", "r":
+			if alias, ok := resultAliases[value]; ok {
+				value = alias
+			}
+			f.result = value
+		case "eco":
+			f.eco = value
+		case "opening", "o":
+			f.opening = value
+		default:
+			f.players = append(f.players, strings.ToLower(field))
+		}
+	}
+	return f
+}
+
+// positionKey reduces a FEN to its piece placement and side to move, which
+// is what makes two positions the same for searching.
+func positionKey(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 2 {
+		fields = fields[:2]
+	}
+	return strings.Join(fields, " ")
+}
+
+func (f pgnFilter) matches(e pgnEntry) bool {
+	lower := func(key string) string { return strings.ToLower(e.tags[key]) }
+	for _, p := range f.players {
+		if !strings.Contains(lower("White"), p) && !strings.Contains(lower("Black"), p) {
+			return false
+		}
+	}
+	switch {
+	case f.white != "" && !strings.Contains(lower("White"), f.white):
+		return false
+	case f.black != "" && !strings.Contains(lower("Black"), f.black):
+		return false
+	case f.result != "" && lower("Result") != f.result:
+		return false
+	case f.eco != "" && !strings.HasPrefix(lower("ECO"), f.eco):
+		return false
+	case f.opening != "" && !strings.Contains(lower("Opening"), f.opening):
+		return false
+	}
+	return true
+}
+
+// searchPositions looks through every game in the file at path for the
+// position key fen, decoding one game at a time.
+func searchPositions(path string, fen string) tea.Cmd {
+	return func() tea.Msg {
+		f, err := os.Open(path)
+		if err != nil {
+			return fenMatchMsg{fen: fen, err: err}
+		}
+		defer f.Close()
+
+		matches := map[int]bool{}
+		idx := 0
+		err = scanPGN(f, func(offset int64, text string) error {
+			if game, err := decodePGN(text); err == nil {
+				for _, pos := range game.Positions() {
+					key := positionKey(pos.String())
+					if key == fen || strings.HasPrefix(key, fen+" ") {
+						matches[idx] = true
+						break
+					}
+				}
+			}
+			idx++
+			return nil
+		})
+		return fenMatchMsg{fen: fen, matches: matches, err: err}
+	}
+}
+
+// applyFilter rebuilds the list of games shown from the filter and sort
+// order, keeping the cursor on the same game when it is still shown.
+func (m *Model) applyFilter() tea.Cmd {
+	selected := -1
+	if m.pgnCursor < len(m.pgnView) {
+		selected = m.pgnView[m.pgnCursor]
+	}
+
+	filter := parseFilter(m.filterField.Value())
+	var cmd tea.Cmd
+	if filter.fen != m.fenQuery {
+		m.fenQuery = filter.fen
+		m.fenMatches = nil
+		if filter.fen != "" {
+			cmd = tea.Tick(fenSearchDelay, func(time.Time) tea.Msg {
+				return fenSearchMsg{fen: filter.fen}
+			})
+		}
+	}
+
+	m.pgnView = m.pgnView[:0]
+	for idx, entry := range m.pgnGames {
+		if !filter.matches(entry) {
+			continue
+		}
+		if filter.fen != "" && !m.fenMatches[idx] {
+			continue
+		}
+		m.pgnView = append(m.pgnView, idx)
+	}
+
+	if col := browserColumns[m.pgnSort]; col.tag != "" {
+		sort.SliceStable(m.pgnView, func(i, j int) bool {
+			a := m.pgnGames[m.pgnView[i]].tags[col.tag]
+			b := m.pgnGames[m.pgnView[j]].tags[col.tag]
+			if m.pgnSortDesc {
+				return a > b
+			}
+			return a < b
+		})
+	} else if m.pgnSortDesc {
+		for i, j := 0, len(m.pgnView)-1; i < j; i, j = i+1, j-1 {
+			m.pgnView[i], m.pgnView[j] = m.pgnView[j], m.pgnView[i]
+		}
+	}
+
+	m.pgnCursor = 0
+	for i, idx := range m.pgnView {
+		if idx == selected {
+			m.pgnCursor = i
+		}
+	}
+	return cmd
+}
+
+func newFilterField() textinput.Model {
+	field := textinput.New()
+	field.Prompt = "/ "
+	field.Placeholder = "carlsen result:1-0 eco:B9 opening:sicilian fen:..."
+	field.Width = width
+	return field
+}
+
+func (m *Model) openEntry() {
+	if len(m.pgnView) == 0 {
+		return
+	}
+	game, err := readPGNEntry(m.pgnPath, m.pgnGames[m.pgnView[m.pgnCursor]])
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.err = nil
+	m.replaying = game
+	m.boardDirection = WhiteDirection
+	m.stepReplay(0)
+	m.mode = ReplayMode
+}
+
+func (m *Model) gameListUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			if m.filterField.Value() != "" {
+				m.filterField.Reset()
+				return m, m.applyFilter()
+			}
+			m.err = nil
+			m.mode = MainMenuMode
+			return m, nil
+		case tea.KeyDown:
+			m.pgnCursor = wrapCursor(m.pgnCursor, 1, len(m.pgnView))
+			return m, nil
+		case tea.KeyUp:
+			m.pgnCursor = wrapCursor(m.pgnCursor, -1, len(m.pgnView))
+			return m, nil
+		case tea.KeyPgDown:
+			m.pgnCursor = max(min(m.pgnCursor+listRows, len(m.pgnView)-1), 0)
+			return m, nil
+		case tea.KeyPgUp:
+			m.pgnCursor = max(m.pgnCursor-listRows, 0)
+			return m, nil
+		case tea.KeyTab:
+			m.pgnSort = wrapCursor(m.pgnSort, 1, len(browserColumns))
+			return m, m.applyFilter()
+		case tea.KeyShiftTab:
+			m.pgnSortDesc = !m.pgnSortDesc
+			return m, m.applyFilter()
+		case tea.KeyEnter:
+			m.openEntry()
+			return m, nil
+		}
+	case fenSearchMsg:
+		if msg.fen != m.fenQuery {
+			return m, nil
+		}
+		return m, searchPositions(m.pgnPath, msg.fen)
+	case fenMatchMsg:
+		if msg.fen != m.fenQuery {
+			return m, nil
+		}
+		m.err = msg.err
+		m.fenMatches = msg.matches
+		return m, m.applyFilter()
+	}
+
+	value := m.filterField.Value()
+	m.filterField, cmd = m.filterField.Update(msg)
+	if m.filterField.Value() != value {
+		m.err = nil
+		return m, tea.Batch(cmd, m.applyFilter())
+	}
+	return m, cmd
+}
+
+func (m *Model) renderGameRow(idx int, entry pgnEntry) string {
+	var cells []string
+	for _, col := range browserColumns {
+		value := strconv.Itoa(idx + 1)
+		if col.tag != "" {
+			value = entry.tag(col.tag)
+		}
+		cells = append(cells, fmt.Sprintf("%-*s", col.width, truncate(value, col.width)))
+	}
+	return " " + strings.Join(cells, " ") + " "
+}
+
+func (m *Model) gameListView() string {
+	var titles []string
+	for idx, col := range browserColumns {
+		title := col.title
+		if idx == m.pgnSort {
+			if m.pgnSortDesc {
+				title += "▼"
+			} else {
+				title += "▲"
+			}
+		}
+		titles = append(titles, fmt.Sprintf("%-*s", col.width, title))
+	}
+	rows := []string{lipgloss.NewStyle().Bold(true).Render(" " + strings.Join(titles, " ") + " ")}
+
+	first := listWindow(m.pgnCursor, listRows, len(m.pgnView))
+	for i := first; i < len(m.pgnView) && i < first+listRows; i++ {
+		row := m.renderGameRow(m.pgnView[i], m.pgnGames[m.pgnView[i]])
+		if i == m.pgnCursor {
+			row = selectedMenuItemStyle.Render(row)
+		}
+		rows = append(rows, row)
+	}
+	for i := len(rows); i <= listRows; i++ {
+		rows = append(rows, "")
+	}
+
+	detail := fmt.Sprintf("%s: %d of %d games", filepath.Base(m.pgnPath), len(m.pgnView), len(m.pgnGames))
+	if m.fenQuery != "" && m.fenMatches == nil && m.err == nil {
+		detail += ", searching positions..."
+	}
+	if len(m.pgnView) > 0 {
+		entry := m.pgnGames[m.pgnView[m.pgnCursor]]
+		detail += fmt.Sprintf("\n%s, %s", entry.tag("Event"), entry.tag("Site"))
+		if opening, ok := entry.tags["Opening"]; ok {
+			detail += "\n" + opening
+		}
+	}
+	if m.err != nil {
+		detail += "\n" + errorStyle.Copy().Width(width).Render(m.err.Error())
+	}
+
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		m.filterField.View(),
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		lipgloss.NewStyle().Margin(1, 0).Render(detail),
+		"enter replay  ↑/↓ pick  tab sort  shift+tab reverse  esc clear/back",
+	)
+}
@@ -0,0 +1,234 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPGN = `[Event "Casual"]
+[White "Morphy, Paul"]
+[Black "Duke Karl"]
+[Result "1-0"]
+[ECO "C41"]
+[Opening "Philidor Defense"]
+
+1. e4 e5 2. Nf3 d6 3. d4 Bg4 1-0
+
+[Event "Comment first"]
+[White "Carlsen, Magnus"]
+[Black "Nepomniachtchi, Ian"]
+[Result "1/2-1/2"]
+[ECO "C88"]
+
+{A quiet game.} 1. e4 e5 2. Nf3 Nc6 1/2-1/2
+
+[Event "Study"]
+[White "Endgame"]
+[Black "Practice"]
+[Result "*"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"]
+
+1. e4 Kd7 2. Kd2 *
+`
+
+func writeTestPGN(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "games.pgn")
+	if err := os.WriteFile(path, []byte(testPGN), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanPGN(t *testing.T) {
+	var offsets []int64
+	var texts []string
+	err := scanPGN(strings.NewReader(testPGN), func(offset int64, text string) error {
+		offsets = append(offsets, offset)
+		texts = append(texts, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 3 {
+		t.Fatalf("scanPGN found %d games, want 3", len(texts))
+	}
+
+	wantMoves := []int{6, 4, 3}
+	for i, text := range texts {
+		if got := testPGN[offsets[i] : offsets[i]+int64(len(text))]; got != text {
+			t.Errorf("game %d: offset %d does not point at its text", i, offsets[i])
+		}
+		game, err := decodePGN(text)
+		if err != nil {
+			t.Errorf("game %d: %v", i, err)
+			continue
+		}
+		if got := len(game.Moves()); got != wantMoves[i] {
+			t.Errorf("game %d has %d moves, want %d", i, got, wantMoves[i])
+		}
+	}
+
+	game, _ := decodePGN(texts[2])
+	if got, want := game.Positions()[0].String(), "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"; got != want {
+		t.Errorf("set-up game starts from %q, want %q", got, want)
+	}
+}
+
+func TestIndexPGN(t *testing.T) {
+	path := writeTestPGN(t)
+	entries, err := indexPGN(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("indexPGN found %d games, want 3", len(entries))
+	}
+	if got := entries[1].tag("White"); got != "Carlsen, Magnus" {
+		t.Errorf("White of game 2 = %q", got)
+	}
+	if got := entries[2].tag("ECO"); got != "?" {
+		t.Errorf("missing ECO = %q, want ?", got)
+	}
+	if _, ok := entries[2].tags["FEN"]; ok {
+		t.Error("tags that are not shown should not be kept")
+	}
+
+	game, err := readPGNEntry(path, entries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := game.GetTagPair("Event"); got == nil || got.Value != "Comment first" {
+		t.Errorf("readPGNEntry read %v, want the second game", got)
+	}
+
+	if _, err := indexPGN(filepath.Join(t.TempDir(), "missing.pgn")); err == nil {
+		t.Error("indexPGN of a missing file did not fail")
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  pgnFilter
+	}{
+		{"", pgnFilter{}},
+		{"Carlsen morphy", pgnFilter{players: []string{"carlsen", "morphy"}}},
+		{"white:Morphy", pgnFilter{white: "morphy"}},
+		{"w:morphy b:Karl", pgnFilter{white: "morphy", black: "karl"}},
+		{"black:karl", pgnFilter{black: "karl"}},
+		{"player:carlsen p:ian", pgnFilter{players: []string{"carlsen", "ian"}}},
+		{"result:1-0", pgnFilter{result: "1-0"}},
+		{"r:white", pgnFilter{result: "1-0"}},
+		{"result:black", pgnFilter{result: "0-1"}},
+		{"result:draw", pgnFilter{result: "1/2-1/2"}},
+		{"r:1/2", pgnFilter{result: "1/2-1/2"}},
+		{"eco:C4", pgnFilter{eco: "c4"}},
+		{"opening:Philidor", pgnFilter{opening: "philidor"}},
+		{"o:sicilian", pgnFilter{opening: "sicilian"}},
+		{"date:1858", pgnFilter{players: []string{"date:1858"}}},
+		{"morphy fen:4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1", pgnFilter{players: []string{"morphy"}, fen: "4k3/8/8/8/4P3/8/8/4K3 b"}},
+		{"FEN:4k3/8/8/8/8/8/8/4K3", pgnFilter{fen: "4k3/8/8/8/8/8/8/4K3"}},
+	}
+	for _, tt := range tests {
+		if got := parseFilter(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFilter(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPositionKey(t *testing.T) {
+	tests := []struct {
+		fen  string
+		want string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b"},
+		{"4k3/8/8/8/8/8/8/4K3 w", "4k3/8/8/8/8/8/8/4K3 w"},
+		{"  4k3/8/8/8/8/8/8/4K3  ", "4k3/8/8/8/8/8/8/4K3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := positionKey(tt.fen); got != tt.want {
+			t.Errorf("positionKey(%q) = %q, want %q", tt.fen, got, tt.want)
+		}
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	entries, err := indexPGN(writeTestPGN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{0, 1, 2}},
+		{"carlsen", []int{1}},
+		{"white:morphy", []int{0}},
+		{"black:morphy", nil},
+		{"result:draw", []int{1}},
+		{"r:white", []int{0}},
+		{"eco:c", []int{0, 1}},
+		{"eco:c8", []int{1}},
+		{"opening:philidor", []int{0}},
+		{"morphy karl", []int{0}},
+		{"morphy carlsen", nil},
+	}
+	for _, tt := range tests {
+		f := parseFilter(tt.query)
+		var got []int
+		for idx, e := range entries {
+			if f.matches(e) {
+				got = append(got, idx)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q matches games %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPositions(t *testing.T) {
+	path := writeTestPGN(t)
+	tests := []struct {
+		fen  string
+		want map[int]bool
+	}{
+		// After 1. e4 e5 2. Nf3, in both of the first two games.
+		{"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b", map[int]bool{0: true, 1: true}},
+		// Only the board, either side to move.
+		{"4k3/8/8/8/4P3/8/8/4K3", map[int]bool{2: true}},
+		{"8/8/8/8/8/8/8/8 w", map[int]bool{}},
+	}
+	for _, tt := range tests {
+		msg := searchPositions(path, tt.fen)().(fenMatchMsg)
+		if msg.err != nil {
+			t.Fatal(msg.err)
+		}
+		if !reflect.DeepEqual(msg.matches, tt.want) {
+			t.Errorf("searchPositions(%q) = %v, want %v", tt.fen, msg.matches, tt.want)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"bubble-chess/engine"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+type bonusMode uint8
+
+const (
+	FischerIncrement = iota
+	BronsteinDelay
+	SimpleDelay
+)
+
+const (
+	clockInterval = 100 * time.Millisecond
+	lowTime       = 20 * time.Second
+)
+
+// timePeriod is one stage of a time control. A period with moves set ends
+// after that many moves, when the time of the next period is added. The
+// bonus is earned on every move, in the way its mode says.
+type timePeriod struct {
+	moves int
+	base  time.Duration
+	bonus time.Duration
+	mode  bonusMode
+}
+
+// timeControl is a list of periods. Untimed games have none.
+type timeControl struct {
+	name    string
+	spec    string
+	periods []timePeriod
+}
+
+type timeControlMsg int
+
+// clockTickMsg wakes the game up to redraw the clocks and look for a fallen
+// flag. Ticks from a clock that has since been replaced carry an old id.
+type clockTickMsg struct {
+	id int
+}
+
+var timeControls = []timeControl{
+	{name: "Untimed"},
+	mustParseTimeControl("Bullet 1+0", "1+0"),
+	mustParseTimeControl("Blitz 3+2", "3+2"),
+	mustParseTimeControl("Blitz 5+3", "5+3"),
+	mustParseTimeControl("Blitz 5, 3s Bronstein", "5+b3"),
+	mustParseTimeControl("Blitz 5, 5s delay", "5+d5"),
+	mustParseTimeControl("Rapid 10+5", "10+5"),
+	mustParseTimeControl("Rapid 15+10", "15+10"),
+	mustParseTimeControl("Classical 40/90, 30+30", "40/90+30,30+30"),
+}
+
+var (
+	clockStyle = lipgloss.NewStyle().
+			Width(columnWidth)
+
+	runningClockStyle = selectedMenuItemStyle.Copy().
+				Width(columnWidth)
+
+	lowClockStyle = lipgloss.NewStyle().
+			Background(red).
+			Foreground(white).
+			Width(columnWidth)
+)
+
+func mustParseTimeControl(name string, spec string) timeControl {
+	tc, err := parseTimeControl(spec)
+	if err != nil {
+		panic(err)
+	}
+	tc.name = name
+	return tc
+}
+
+// parseTimeControl reads time controls written as comma separated periods
+// of [moves/]minutes[+seconds], such as 5+3 or 40/90+30,30+30. The seconds
+// are a Fischer increment unless prefixed with b for a Bronstein delay or d
+// for a simple delay, as in 5+b3. An empty spec or "-" is an untimed game.
+func parseTimeControl(spec string) (timeControl, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "-" {
+		return timeControl{name: "Untimed"}, nil
+	}
+
+	tc := timeControl{name: spec, spec: spec}
+	fields := strings.Split(spec, ",")
+	for i, field := range fields {
+		var p timePeriod
+		field = strings.TrimSpace(field)
+		if moves, rest, ok := strings.Cut(field, "/"); ok {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return tc, fmt.Errorf("%q does not start with a number of moves", field)
+			}
+			p.moves = n
+			field = rest
+		} else if i < len(fields)-1 {
+			return tc, fmt.Errorf("Only the last period of %q can leave out its number of moves", spec)
+		}
+
+		minutes, bonus, hasBonus := strings.Cut(field, "+")
+		base, err := parseDuration(minutes, time.Minute)
+		if err != nil || base <= 0 {
+			return tc, fmt.Errorf("%q does not start with a number of minutes", field)
+		}
+		p.base = base
+		if hasBonus {
+			switch {
+			case strings.HasPrefix(bonus, "b"):
+				p.mode, bonus = BronsteinDelay, bonus[1:]
+			case strings.HasPrefix(bonus, "d"):
+				p.mode, bonus = SimpleDelay, bonus[1:]
+			}
+			if p.bonus, err = parseDuration(bonus, time.Second); err != nil || p.bonus < 0 {
+				return tc, fmt.Errorf("%q does not end with a number of seconds", field)
+			}
+		}
+		tc.periods = append(tc.periods, p)
+	}
+	return tc, nil
+}
+
+func parseDuration(s string, unit time.Duration) (time.Duration, error) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// pgnTag returns the time control as the PGN TimeControl tag writes it.
+// The tag has no way to write a delay, so only the base time of periods
+// with one is given.
+func (tc timeControl) pgnTag() string {
+	var fields []string
+	for _, p := range tc.periods {
+		field := strconv.Itoa(int(p.base.Seconds()))
+		if p.moves > 0 {
+			field = fmt.Sprintf("%d/%s", p.moves, field)
+		}
+		if p.mode == FischerIncrement && p.bonus > 0 {
+			field += "+" + strconv.Itoa(int(p.bonus.Seconds()))
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, ":")
+}
+
+// chessClock keeps the time of both players. Its arrays are indexed by
+// chess.Color.
+type chessClock struct {
+	control     timeControl
+	remaining   [3]time.Duration
+	period      [3]int
+	periodMoves [3]int
+	running     chess.Color
+	since       time.Time
+}
+
+func newClock(tc timeControl) *chessClock {
+	if len(tc.periods) == 0 {
+		return nil
+	}
+	c := &chessClock{control: tc}
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		c.remaining[color] = tc.periods[0].base
+	}
+	return c
+}
+
+func (c *chessClock) currentPeriod(color chess.Color) timePeriod {
+	return c.control.periods[c.period[color]]
+}
+
+// start runs the clock of color from now on.
+func (c *chessClock) start(color chess.Color, now time.Time) {
+	c.running = color
+	c.since = now
+}
+
+// stop freezes both clocks.
+func (c *chessClock) stop(now time.Time) {
+	if c.running != chess.NoColor {
+		c.remaining[c.running] = c.left(c.running, now)
+	}
+	c.running = chess.NoColor
+}
+
+// left returns the time color has left at now.
+func (c *chessClock) left(color chess.Color, now time.Time) time.Duration {
+	if color != c.running {
+		return c.remaining[color]
+	}
+	used := now.Sub(c.since)
+	if p := c.currentPeriod(color); p.mode == SimpleDelay {
+		used -= p.bonus
+		if used < 0 {
+			used = 0
+		}
+	}
+	return c.remaining[color] - used
+}
+
+// press ends the move of the side whose clock is running, adding whatever
+// it has earned, and starts the other side's clock.
+func (c *chessClock) press(now time.Time) {
+	color := c.running
+	p := c.currentPeriod(color)
+	left := c.left(color, now)
+	switch p.mode {
+	case FischerIncrement:
+		left += p.bonus
+	case BronsteinDelay:
+		if used := now.Sub(c.since); used < p.bonus {
+			left += used
+		} else {
+			left += p.bonus
+		}
+	}
+
+	c.periodMoves[color]++
+	if p.moves > 0 && c.periodMoves[color] == p.moves {
+		// The last period repeats when it also has a number of moves.
+		if c.period[color] < len(c.control.periods)-1 {
+			c.period[color]++
+		}
+		c.periodMoves[color] = 0
+		left += c.currentPeriod(color).base
+	}
+	c.remaining[color] = left
+	c.start(color.Other(), now)
+}
+
+// periodAt returns the period a side is in once it has made n moves, how
+// many of those were made in it, and the time added on reaching periods
+// after the first.
+func (tc timeControl) periodAt(n int) (int, int, time.Duration) {
+	period, moves := 0, 0
+	var added time.Duration
+	for i := 0; i < n; i++ {
+		moves++
+		if p := tc.periods[period]; p.moves > 0 && moves == p.moves {
+			if period < len(tc.periods)-1 {
+				period++
+			}
+			moves = 0
+			added += tc.periods[period].base
+		}
+	}
+	return period, moves, added
+}
+
+// takeBack rewinds the periods of color from where they stood after made
+// moves of its own to where they stood after kept, taking away the time of
+// any period reached in between. Time spent on the moves is not refunded.
+func (c *chessClock) takeBack(color chess.Color, made int, kept int) {
+	_, _, before := c.control.periodAt(made)
+	period, moves, after := c.control.periodAt(kept)
+	c.period[color] = period
+	c.periodMoves[color] = moves
+	c.remaining[color] -= before - after
+}
+
+// flagged returns the side whose time has run out, if any.
+func (c *chessClock) flagged(now time.Time) chess.Color {
+	if c.running != chess.NoColor && c.left(c.running, now) <= 0 {
+		return c.running
+	}
+	return chess.NoColor
+}
+
+// budget returns how long color can afford to think about its next move.
+func (c *chessClock) budget(color chess.Color, now time.Time) time.Duration {
+	p := c.currentPeriod(color)
+	movesToGo := 0
+	if p.moves > 0 {
+		movesToGo = p.moves - c.periodMoves[color]
+	}
+	return engine.TimeBudget(c.left(color, now), p.bonus, movesToGo)
+}
+
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	if d < lowTime {
+		return fmt.Sprintf("0:%04.1f", float64(d/(time.Second/10))/10)
+	}
+	d = d.Truncate(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+func timeControlMenuItems(controls []timeControl) []MenuItem {
+	var items []MenuItem
+	for idx, control := range controls {
+		idx := idx
+		items = append(items, MenuItem{
+			title:  control.name,
+			action: func() tea.Msg { return timeControlMsg(idx) },
+		})
+	}
+	return items
+}
+
+// setTimeControl makes tc the time control new games are played with,
+// adding it to the menu when it is not one of the presets.
+func (m *Model) setTimeControl(tc timeControl) {
+	m.control = tc
+	m.timeControls = timeControls
+	m.timeControlCursor = -1
+	for i, preset := range timeControls {
+		if preset.spec == tc.spec {
+			m.timeControlCursor = i
+		}
+	}
+	if m.timeControlCursor < 0 {
+		m.timeControls = append([]timeControl{tc}, timeControls...)
+		m.timeControlCursor = 0
+	}
+	m.timeControlItems = timeControlMenuItems(m.timeControls)
+}
+
+// startClock sets the clock up for a new game and, when the game is timed,
+// starts it ticking for the side to move.
+func (m *Model) startClock() tea.Cmd {
+	m.flagged = chess.NoColor
+	m.timeControl = m.control.pgnTag()
+	m.clock = newClock(m.control)
+	return m.resumeClock()
+}
+
+func (m *Model) resumeClock() tea.Cmd {
+	if m.clock == nil || m.game.Outcome() != chess.NoOutcome {
+		return nil
+	}
+	m.clock.start(m.game.Position().Turn(), time.Now())
+	m.clockID++
+	return m.tickClock()
+}
+
+func (m *Model) tickClock() tea.Cmd {
+	id := m.clockID
+	return tea.Tick(clockInterval, func(time.Time) tea.Msg {
+		return clockTickMsg{id: id}
+	})
+}
+
+func (m *Model) stopClock() {
+	if m.clock != nil {
+		m.clock.stop(time.Now())
+	}
+	m.clockID++
+}
+
+// checkFlag ends the game if the side to move has run out of time, and
+// reports whether it did.
+func (m *Model) checkFlag() bool {
+	if m.clock == nil || m.flagged != chess.NoColor {
+		return m.flagged != chess.NoColor
+	}
+	if color := m.clock.flagged(time.Now()); color != chess.NoColor {
+		m.flagged = color
+		m.stopClock()
+		return true
+	}
+	return false
+}
+
+// pressClock hands the move over to the other side's clock once a move has
+// been played, or stops the clocks if it ended the game.
+func (m *Model) pressClock() {
+	if m.clock == nil {
+		return
+	}
+	if m.game.Outcome() != chess.NoOutcome {
+		m.stopClock()
+		return
+	}
+	m.clock.press(time.Now())
+}
+
+// outcome is the result of the game, counting a fallen flag as a loss
+// unless the other side has nothing left to mate with.
+func (m *Model) outcome() chess.Outcome {
+	if m.flagged == chess.NoColor {
+		return m.game.Outcome()
+	}
+	if !canMate(m.game.Position().Board(), m.flagged.Other()) {
+		return chess.Draw
+	}
+	if m.flagged == chess.White {
+		return chess.BlackWon
+	}
+	return chess.WhiteWon
+}
+
+// movesBy returns how many of the first plies moves of a game, whose
+// positions are given, color made.
+func movesBy(positions []*chess.Position, color chess.Color, plies int) int {
+	n := 0
+	for _, pos := range positions[:plies] {
+		if pos.Turn() == color {
+			n++
+		}
+	}
+	return n
+}
+
+// canMate reports whether color has enough material left that some
+// sequence of legal moves could end in mate.
+func canMate(b *chess.Board, color chess.Color) bool {
+	knights, others := 0, 0
+	// Bishops are counted by the color of their squares, dark first.
+	var bishops, otherBishops [2]int
+	for sq, p := range b.SquareMap() {
+		shade := (int(sq.File()) + int(sq.Rank())) % 2
+		if p.Color() != color {
+			switch p.Type() {
+			case chess.King:
+			case chess.Bishop:
+				otherBishops[shade]++
+			default:
+				others++
+			}
+			continue
+		}
+		switch p.Type() {
+		case chess.Pawn, chess.Rook, chess.Queen:
+			return true
+		case chess.Knight:
+			knights++
+		case chess.Bishop:
+			bishops[shade]++
+		}
+	}
+	if knights == 0 && (bishops[0] == 0 || bishops[1] == 0) {
+		if bishops[0] == 0 && bishops[1] == 0 {
+			return false
+		}
+		// Bishops that all keep to one color of square need the other
+		// side's pieces to hem its king in, and its bishops on that same
+		// color never can.
+		shade := 0
+		if bishops[1] > 0 {
+			shade = 1
+		}
+		return others > 0 || otherBishops[1-shade] > 0
+	}
+	// A lone knight can only mate with the help of the other side's pieces
+	// hemming its king in.
+	return knights+bishops[0]+bishops[1] > 1 || others+otherBishops[0]+otherBishops[1] > 0
+}
+
+func (m *Model) renderClock(color chess.Color) string {
+	left := m.clock.left(color, time.Now())
+	text := fmt.Sprintf("%s %s", color.Name(), formatClock(left))
+	switch {
+	case m.flagged == color:
+		return lowClockStyle.Render(text + " flag")
+	case m.clock.running == color && left < lowTime:
+		return lowClockStyle.Render(text)
+	case m.clock.running == color:
+		return runningClockStyle.Render(text)
+	}
+	return clockStyle.Render(text)
+}
+
+// renderClocks returns the clocks of the sides at the top and the bottom of
+// the board.
+func (m *Model) renderClocks() (top string, bottom string) {
+	if m.clock == nil {
+		return "", ""
+	}
+	if m.boardDirection == WhiteDirection {
+		return m.renderClock(chess.Black), m.renderClock(chess.White)
+	}
+	return m.renderClock(chess.White), m.renderClock(chess.Black)
+}
+
+func (m *Model) timeControlUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			if m.opponent == ComputerOpponent {
+				m.mode = DifficultyMode
+			} else {
+				m.mode = MainMenuMode
+			}
+		case tea.KeyEnter:
+			return m, m.timeControlItems[m.timeControlCursor].action
+		case tea.KeyDown:
+			m.timeControlCursor = wrapCursor(m.timeControlCursor, 1, len(m.timeControlItems))
+		case tea.KeyUp:
+			m.timeControlCursor = wrapCursor(m.timeControlCursor, -1, len(m.timeControlItems))
+		}
+		return m, nil
+	case timeControlMsg:
+		m.control = m.timeControls[msg]
+		if m.opponent == ComputerOpponent {
+			m.mode = SideMode
+			return m, nil
+		}
+		m.newGame(HumanOpponent)
+		return m, m.beginGame()
+	}
+
+	return m, nil
+}
+
+func (m *Model) timeControlView() string {
+	var detail string
+	for i, p := range m.timeControls[m.timeControlCursor].periods {
+		if i > 0 {
+			detail += "\nthen "
+		}
+		detail += describePeriod(p)
+	}
+	if detail == "" {
+		detail = "No clocks"
+	}
+	return setupView(m.timeControlItems, m.timeControlCursor, "Time control\n\n"+detail)
+}
+
+func describePeriod(p timePeriod) string {
+	s := fmt.Sprintf("%s min", strconv.FormatFloat(p.base.Minutes(), 'f', -1, 64))
+	if p.moves > 0 {
+		s = fmt.Sprintf("%s for %d moves", s, p.moves)
+	}
+	if p.bonus > 0 {
+		seconds := strconv.FormatFloat(p.bonus.Seconds(), 'f', -1, 64)
+		switch p.mode {
+		case FischerIncrement:
+			s += fmt.Sprintf(", +%ss a move", seconds)
+		case BronsteinDelay:
+			s += fmt.Sprintf(", %ss Bronstein delay", seconds)
+		case SimpleDelay:
+			s += fmt.Sprintf(", %ss delay", seconds)
+		}
+	}
+	return s
+}
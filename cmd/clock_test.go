@@ -0,0 +1,221 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		spec    string
+		periods []timePeriod
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"-", nil, false},
+		{"5", []timePeriod{{base: 5 * time.Minute}}, false},
+		{"3+2", []timePeriod{{base: 3 * time.Minute, bonus: 2 * time.Second}}, false},
+		{"0.5+0", []timePeriod{{base: 30 * time.Second}}, false},
+		{"5+b3", []timePeriod{{base: 5 * time.Minute, bonus: 3 * time.Second, mode: BronsteinDelay}}, false},
+		{"5+d5", []timePeriod{{base: 5 * time.Minute, bonus: 5 * time.Second, mode: SimpleDelay}}, false},
+		{"40/90+30,30+30", []timePeriod{
+			{moves: 40, base: 90 * time.Minute, bonus: 30 * time.Second},
+			{base: 30 * time.Minute, bonus: 30 * time.Second},
+		}, false},
+		{"40/120, 20/60, 15", []timePeriod{
+			{moves: 40, base: 120 * time.Minute},
+			{moves: 20, base: 60 * time.Minute},
+			{base: 15 * time.Minute},
+		}, false},
+		{"abc", nil, true},
+		{"0+5", nil, true},
+		{"5+x", nil, true},
+		{"5+-1", nil, true},
+		{"0/90", nil, true},
+		{"x/90", nil, true},
+		{"90,30", nil, true},
+	}
+	for _, tt := range tests {
+		tc, err := parseTimeControl(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTimeControl(%q) = %+v, want an error", tt.spec, tc.periods)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTimeControl(%q): %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.periods, tt.periods) {
+			t.Errorf("parseTimeControl(%q) = %+v, want %+v", tt.spec, tc.periods, tt.periods)
+		}
+	}
+}
+
+func TestClockPress(t *testing.T) {
+	tests := []struct {
+		spec  string
+		spent time.Duration
+		want  time.Duration
+	}{
+		{"1+2", 5 * time.Second, 57 * time.Second},
+		{"1+b3", 5 * time.Second, 58 * time.Second},
+		{"1+b3", 2 * time.Second, time.Minute},
+		{"1+d3", 5 * time.Second, 58 * time.Second},
+		{"1+d3", 2 * time.Second, time.Minute},
+		{"1", 5 * time.Second, 55 * time.Second},
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		c := newClock(mustParseTimeControl(tt.spec, tt.spec))
+		c.start(chess.White, start)
+		c.press(start.Add(tt.spent))
+		if got := c.left(chess.White, start.Add(time.Hour)); got != tt.want {
+			t.Errorf("%s: %v spent leaves %v, want %v", tt.spec, tt.spent, got, tt.want)
+		}
+		if c.running != chess.Black {
+			t.Errorf("%s: pressing did not start black's clock", tt.spec)
+		}
+	}
+}
+
+func TestClockPeriods(t *testing.T) {
+	c := newClock(mustParseTimeControl("", "2/1,1/2,3"))
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	press := func(n int) {
+		for i := 0; i < n; i++ {
+			c.start(chess.White, now)
+			c.press(now)
+		}
+	}
+
+	press(1)
+	if c.period[chess.White] != 0 || c.remaining[chess.White] != time.Minute {
+		t.Fatalf("after 1 move: period %d with %v", c.period[chess.White], c.remaining[chess.White])
+	}
+	press(1)
+	if c.period[chess.White] != 1 || c.remaining[chess.White] != 3*time.Minute {
+		t.Fatalf("after 2 moves: period %d with %v", c.period[chess.White], c.remaining[chess.White])
+	}
+	press(1)
+	if c.period[chess.White] != 2 || c.remaining[chess.White] != 6*time.Minute {
+		t.Fatalf("after 3 moves: period %d with %v", c.period[chess.White], c.remaining[chess.White])
+	}
+
+	// Taking back the last two moves leaves white one move into the
+	// first period, without the time of the two it reached since.
+	c.takeBack(chess.White, 3, 1)
+	if c.period[chess.White] != 0 || c.periodMoves[chess.White] != 1 || c.remaining[chess.White] != time.Minute {
+		t.Errorf("after taking back to 1 move: period %d, %d moves in, %v left", c.period[chess.White], c.periodMoves[chess.White], c.remaining[chess.White])
+	}
+	press(1)
+	if c.period[chess.White] != 1 || c.remaining[chess.White] != 3*time.Minute {
+		t.Errorf("after replaying move 2: period %d with %v", c.period[chess.White], c.remaining[chess.White])
+	}
+}
+
+func TestPeriodAt(t *testing.T) {
+	tc := mustParseTimeControl("", "40/90+30,30+30")
+	tests := []struct {
+		moves  int
+		period int
+		in     int
+		added  time.Duration
+	}{
+		{0, 0, 0, 0},
+		{39, 0, 39, 0},
+		{40, 1, 0, 30 * time.Minute},
+		{75, 1, 35, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		period, in, added := tc.periodAt(tt.moves)
+		if period != tt.period || in != tt.in || added != tt.added {
+			t.Errorf("periodAt(%d) = %d, %d, %v, want %d, %d, %v", tt.moves, period, in, added, tt.period, tt.in, tt.added)
+		}
+	}
+
+	// A last period with a number of moves repeats.
+	tc = mustParseTimeControl("", "2/1,2/1")
+	if period, in, added := tc.periodAt(6); period != 1 || in != 0 || added != 3*time.Minute {
+		t.Errorf("repeating periodAt(6) = %d, %d, %v", period, in, added)
+	}
+}
+
+func TestCanMate(t *testing.T) {
+	tests := []struct {
+		fen   string
+		white bool
+		black bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/3QK3 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/1NB1K3 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/1NN1K3 w - - 0 1", true, false},
+		{"4k3/4p3/8/8/8/8/8/2B1K3 w - - 0 1", true, true},
+		{"4k1b1/8/8/8/8/8/8/2B1K3 w - - 0 1", true, true},
+		{"4kb2/8/8/8/8/8/8/2B1K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/4B3/8/2B1K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/4B3/4n3/2B1K3 w - - 0 1", true, true},
+	}
+	for _, tt := range tests {
+		opt, err := chess.FEN(tt.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := chess.NewGame(opt).Position().Board()
+		if got := canMate(b, chess.White); got != tt.white {
+			t.Errorf("%s: white canMate = %v, want %v", tt.fen, got, tt.white)
+		}
+		if got := canMate(b, chess.Black); got != tt.black {
+			t.Errorf("%s: black canMate = %v, want %v", tt.fen, got, tt.black)
+		}
+	}
+}
+
+func TestTakebackRewindsClock(t *testing.T) {
+	m := New("")
+	m.setTimeControl(mustParseTimeControl("", "2/1,5"))
+	m.newGame(HumanOpponent)
+	m.startClock()
+	for _, mv := range []string{"e4", "e5", "Nf3"} {
+		if err := m.game.MoveStr(mv); err != nil {
+			t.Fatal(err)
+		}
+		m.pressClock()
+	}
+	if m.clock.period[chess.White] != 1 {
+		t.Fatalf("white is in period %d after two moves, want 1", m.clock.period[chess.White])
+	}
+	m.takeback()
+	if p, n := m.clock.period[chess.White], m.clock.periodMoves[chess.White]; p != 0 || n != 1 {
+		t.Errorf("after the takeback white is %d moves into period %d, want 1 into 0", n, p)
+	}
+	if left := m.clock.left(chess.White, time.Now()); left > time.Minute {
+		t.Errorf("white kept the next period's time: %v left", left)
+	}
+}
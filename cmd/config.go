@@ -0,0 +1,261 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"bubble-chess/engine"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configFormats are the extensions a config file may have, in the order
+// they are looked for.
+var configFormats = []string{".toml", ".yaml", ".yml"}
+
+var sideNames = []string{"white", "black", "random"}
+
+// config is what config.toml or config.yaml holds. Settings left out keep
+// their defaults, and flags given on the command line win over both.
+type config struct {
+	Notation    string            `toml:"notation,omitempty" yaml:"notation,omitempty"`
+	Theme       string            `toml:"theme,omitempty" yaml:"theme,omitempty"`
+	Pieces      string            `toml:"pieces,omitempty" yaml:"pieces,omitempty"`
+	Side        string            `toml:"side,omitempty" yaml:"side,omitempty"`
+	Difficulty  int               `toml:"difficulty,omitempty" yaml:"difficulty,omitempty"`
+	TimeControl string            `toml:"time-control,omitempty" yaml:"time-control,omitempty"`
+	Takebacks   *bool             `toml:"takebacks,omitempty" yaml:"takebacks,omitempty"`
+	Mouse       *bool             `toml:"mouse,omitempty" yaml:"mouse,omitempty"`
+	PGNDir      string            `toml:"pgn-dir,omitempty" yaml:"pgn-dir,omitempty"`
+	Annotator   string            `toml:"annotator,omitempty" yaml:"annotator,omitempty"`
+	Keys        map[string]string `toml:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// findConfigFile returns the path of name.toml, name.yaml or name.yml in
+// the config directory, whichever comes first, or "" when there is none.
+func findConfigFile(name string) (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		// Without a home directory there is nowhere to keep any.
+		return "", nil
+	}
+	for _, ext := range configFormats {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// readConfigFile decodes the TOML or YAML file at path, going by its
+// extension.
+func readConfigFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, v)
+	default:
+		err = toml.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+func writeConfigFile(path string, v any) error {
+	var data []byte
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		var err error
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	default:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadConfig reads the config file at path, or the one in the config
+// directory when path is empty. It also returns where settings are saved
+// to, which is config.toml when there is no file yet.
+func loadConfig(path string) (config, string, error) {
+	var cfg config
+	if path == "" {
+		found, err := findConfigFile("config")
+		if err != nil {
+			return cfg, "", err
+		}
+		if found == "" {
+			if dir, err := configDir(); err == nil {
+				return cfg, filepath.Join(dir, "config.toml"), nil
+			}
+			return cfg, "", nil
+		}
+		path = found
+	}
+	return cfg, path, readConfigFile(path, &cfg)
+}
+
+// configFlags fills in each flag left off the command line from cfg.
+func configFlags(cmd *cobra.Command, cfg config) {
+	flags := cmd.Flags()
+	set := func(name string, value string) {
+		if value != "" && !flags.Changed(name) {
+			flags.Set(name, value)
+		}
+	}
+	set("notation", cfg.Notation)
+	set("theme", cfg.Theme)
+	set("pieces", cfg.Pieces)
+	set("time-control", cfg.TimeControl)
+	pgnDir := cfg.PGNDir
+	if rest, ok := strings.CutPrefix(pgnDir, "~/"); ok {
+		// Unlike on the command line, no shell expands ~ in the file.
+		if home, err := os.UserHomeDir(); err == nil {
+			pgnDir = filepath.Join(home, rest)
+		}
+	}
+	set("pgn-dir", pgnDir)
+	set("annotator", cfg.Annotator)
+	if cfg.Takebacks != nil {
+		set("no-takebacks", strconv.FormatBool(!*cfg.Takebacks))
+	}
+	if cfg.Mouse != nil {
+		set("no-mouse", strconv.FormatBool(!*cfg.Mouse))
+	}
+}
+
+// applyConfig sets up what has no flag of its own: the side and
+// difficulty picked first in the menus, and the key bindings.
+func (m *Model) applyConfig(cfg config) error {
+	if cfg.Side != "" {
+		side := -1
+		for idx, name := range sideNames {
+			if strings.EqualFold(cfg.Side, name) {
+				side = idx
+			}
+		}
+		if side < 0 {
+			return fmt.Errorf("unknown side %q, expected one of %s", cfg.Side, strings.Join(sideNames, ", "))
+		}
+		m.sideCursor = side
+	}
+	if cfg.Difficulty != 0 {
+		if cfg.Difficulty < 1 || cfg.Difficulty > len(engine.Levels) {
+			return fmt.Errorf("difficulty %d is out of range, expected 1 to %d", cfg.Difficulty, len(engine.Levels))
+		}
+		m.difficultyCursor = cfg.Difficulty - 1
+	}
+	keys, err := bindKeys(cfg.Keys)
+	if err != nil {
+		return err
+	}
+	m.keys = keys
+
+	// Only what the file holds is saved back to it, so that flags given
+	// once are not kept. The settings screen starts from what is in
+	// effect, flags included.
+	m.config = cfg
+	takebacks := m.takebacks
+	m.settings = config{
+		Notation:    strings.ToLower(m.notation.String()),
+		Theme:       m.theme.name,
+		Pieces:      m.pieces.String(),
+		Side:        sideNames[m.sideCursor],
+		Difficulty:  m.difficultyCursor + 1,
+		TimeControl: m.control.spec,
+		Takebacks:   &takebacks,
+	}
+	return nil
+}
+
+// setDisplay puts the named notation, piece set and theme to use.
+func (m *Model) setDisplay(notationName string, pieceSetName string, themeName string) error {
+	n, err := parseNotation(notationName)
+	if err != nil {
+		return err
+	}
+	m.setNotation(n)
+	if m.pieces, err = parsePieceSet(pieceSetName); err != nil {
+		return err
+	}
+	if m.themes, err = loadThemes(); err != nil {
+		return err
+	}
+	t, err := findTheme(m.themes, themeName)
+	if err != nil {
+		return err
+	}
+	m.setTheme(t)
+	return nil
+}
+
+// keyBindings returns the bindings in keys that differ from the defaults,
+// by action name.
+func keyBindings(keys keyMap) map[string]string {
+	var bindings map[string]string
+	for idx, key := range keys {
+		if key != defaultKeys[idx] {
+			if bindings == nil {
+				bindings = map[string]string{}
+			}
+			bindings[keyAction(idx).String()] = key
+		}
+	}
+	return bindings
+}
+
+// saveConfig writes the settings to the config file.
+func (m *Model) saveConfig() error {
+	if m.configPath == "" {
+		return errors.New("There is no config directory to save settings to")
+	}
+	if err := writeConfigFile(m.configPath, m.config); err != nil {
+		return fmt.Errorf("Could not save settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsKeepFlagsOutOfFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("notation = \"lan\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As if run with --theme wood.
+	m := New("")
+	if err := m.setDisplay(cfg.Notation, "unicode", "wood"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.applyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	m.configPath = path
+	if got := m.settingValue(ThemeSetting); got != "wood" {
+		t.Errorf("settings show theme %q, want the one in effect", got)
+	}
+
+	m.changeSetting(PiecesSetting, 1)
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	var saved config
+	if err := readConfigFile(path, &saved); err != nil {
+		t.Fatal(err)
+	}
+	want := config{Notation: "lan", Pieces: pieceSet(1).String()}
+	if saved.Notation != want.Notation || saved.Pieces != want.Pieces || saved.Theme != "" || saved.TimeControl != "" || saved.Takebacks != nil || saved.Difficulty != 0 {
+		t.Errorf("saved %+v, want only %+v", saved, want)
+	}
+}
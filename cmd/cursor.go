@@ -0,0 +1,229 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+var cursorStyle = lipgloss.NewStyle().
+	Background(yellow)
+
+// moveCursor moves the board cursor by a number of files and ranks as seen
+// on screen, which are reversed when the board is flipped.
+func (m *Model) moveCursor(files int, ranks int) {
+	if m.boardDirection == BlackDirection {
+		files, ranks = -files, -ranks
+	}
+	f := int(m.cursor.File()) + files
+	r := int(m.cursor.Rank()) + ranks
+	if f < 0 || f > 7 || r < 0 || r > 7 {
+		return
+	}
+	m.cursor = chess.NewSquare(chess.File(f), chess.Rank(r))
+	m.err = nil
+	m.showSelection()
+}
+
+// movesFrom returns the legal moves of the piece on sq.
+func (m *Model) movesFrom(sq chess.Square) []chess.Move {
+	var moves []chess.Move
+	for _, mov := range m.game.ValidMoves() {
+		if mov.S1() == sq {
+			moves = append(moves, *mov)
+		}
+	}
+	return moves
+}
+
+// originText is what would be typed so far in the current notation for a
+// move of the piece on sq.
+func (m *Model) originText(sq chess.Square) string {
+	p := m.game.Position().Board().Piece(sq)
+	letter := strings.ToUpper(p.Type().String())
+	if p.Type() == chess.Pawn {
+		letter = ""
+	}
+	switch m.notation {
+	case LANNotation:
+		return letter + sq.String()
+	case UCINotation:
+		return sq.String()
+	}
+	if letter == "" {
+		return sq.File().String()
+	}
+	return letter
+}
+
+// showSelection puts the move being formed on the board into the move
+// field and highlights the selected piece. Where it can go is marked by
+// renderPosition.
+func (m *Model) showSelection() {
+	if m.selected == chess.NoSquare {
+		m.nextMoveField.Reset()
+		m.refreshGuesses()
+		return
+	}
+
+	text := m.originText(m.selected)
+	for _, mov := range m.movesFrom(m.selected) {
+		if mov.S2() == m.cursor && (mov.Promo() == chess.NoPieceType || mov.Promo() == chess.Queen) {
+			text = m.renderMove(mov)
+		}
+	}
+	m.nextMoveField.SetValue(text)
+	m.nextMoveField.CursorEnd()
+	m.guessList = []chess.Move{}
+	m.guessCursor = NO_GUESS
+	m.guessMenu = ""
+	m.highlightsBoard = toBitboard([]chess.Square{m.selected})
+}
+
+func (m *Model) selectSquare(sq chess.Square) {
+	m.selected = sq
+	m.showSelection()
+}
+
+// pickSquare selects the piece under the cursor, or plays the selected
+// piece's move to it.
+func (m *Model) pickSquare() tea.Cmd {
+	if m.thinking {
+		return nil
+	}
+	m.err = nil
+	sq := m.cursor
+	board := m.game.Position().Board()
+
+	if m.selected != chess.NoSquare {
+		var choices []chess.Move
+		for _, mov := range m.movesFrom(m.selected) {
+			if mov.S2() == sq {
+				choices = append(choices, mov)
+			}
+		}
+		switch {
+		case len(choices) == 1:
+			m.selected = chess.NoSquare
+			return m.playMove(&choices[0])
+		case len(choices) > 1:
+			var promotions []chess.Move
+			for _, pt := range promotionPieces {
+				for _, mov := range choices {
+					if mov.Promo() == pt {
+						promotions = append(promotions, mov)
+					}
+				}
+			}
+			m.selected = chess.NoSquare
+			m.openPromotion(promotions)
+			return nil
+		case sq == m.selected:
+			m.selectSquare(chess.NoSquare)
+			return nil
+		}
+	}
+
+	p := board.Piece(sq)
+	switch {
+	case p == chess.NoPiece || p.Color() != m.game.Position().Turn():
+		if m.selected != chess.NoSquare {
+			from := board.Piece(m.selected)
+			m.err = fmt.Errorf("The %s on %s cannot go to %s", pieceName(from.Type()), m.selected, sq)
+		} else {
+			m.err = fmt.Errorf("There is no %s piece on %s", strings.ToLower(m.game.Position().Turn().Name()), sq)
+		}
+	case len(m.movesFrom(sq)) == 0:
+		m.err = fmt.Errorf("The %s on %s has no legal moves", pieceName(p.Type()), sq)
+	default:
+		m.selectSquare(sq)
+	}
+	return nil
+}
+
+func (m *Model) focusBoard() {
+	m.boardFocus = true
+	m.nextMoveField.Blur()
+	m.selectSquare(chess.NoSquare)
+}
+
+func (m *Model) blurBoard() tea.Cmd {
+	m.boardFocus = false
+	m.selectSquare(chess.NoSquare)
+	return m.nextMoveField.Focus()
+}
+
+// boardUpdate handles the keys that drive the board cursor and reports
+// whether it did. The arrow keys take the focus to the board whenever
+// there is nothing typed in the move field.
+func (m *Model) boardUpdate(msg tea.KeyMsg) (tea.Cmd, bool) {
+	arrow := msg.Type == tea.KeyUp || msg.Type == tea.KeyDown ||
+		msg.Type == tea.KeyLeft || msg.Type == tea.KeyRight
+	if !m.boardFocus {
+		switch {
+		case m.keys.lookup(msg) == BoardKey:
+			m.focusBoard()
+			return nil, true
+		case arrow && m.nextMoveField.Value() == "":
+			m.focusBoard()
+		default:
+			return nil, false
+		}
+	}
+
+	if m.keys.lookup(msg) == BoardKey {
+		return m.blurBoard(), true
+	}
+	switch msg.String() {
+	case "up", "k":
+		m.moveCursor(0, 1)
+	case "down", "j":
+		m.moveCursor(0, -1)
+	case "left", "h":
+		m.moveCursor(-1, 0)
+	case "right", "l":
+		m.moveCursor(1, 0)
+	case "enter", " ":
+		return m.pickSquare(), true
+	case "esc", "backspace":
+		if m.selected != chess.NoSquare {
+			m.selectSquare(chess.NoSquare)
+			return nil, true
+		}
+		if msg.Type == tea.KeyEsc {
+			return m.blurBoard(), true
+		}
+	default:
+		if msg.Type == tea.KeyRunes {
+			// Typing hands the move back to the move field.
+			m.blurBoard()
+			return nil, false
+		}
+		return nil, false
+	}
+	return nil, true
+}
+
+// cursorAt reports whether the board cursor should be drawn on sq.
+func (m *Model) cursorAt(sq chess.Square) bool {
+	return m.boardFocus && m.mode == GameMode && sq == m.cursor
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/notnil/chess"
+)
+
+// boardKey drives the board with the key named as in tea.KeyMsg.String.
+func boardKey(m *Model, name string) tea.Cmd {
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+	switch name {
+	case "up":
+		msg = tea.KeyMsg{Type: tea.KeyUp}
+	case "down":
+		msg = tea.KeyMsg{Type: tea.KeyDown}
+	case "left":
+		msg = tea.KeyMsg{Type: tea.KeyLeft}
+	case "right":
+		msg = tea.KeyMsg{Type: tea.KeyRight}
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	cmd, _ := m.boardUpdate(msg)
+	return cmd
+}
+
+func TestCursorMovesAsSeen(t *testing.T) {
+	tests := []struct {
+		direction direction
+		keys      []string
+		want      chess.Square
+	}{
+		{WhiteDirection, []string{"up"}, chess.E3},
+		{WhiteDirection, []string{"k", "l", "l"}, chess.G3},
+		{WhiteDirection, []string{"down", "down"}, chess.E1},
+		{WhiteDirection, []string{"h", "h", "h", "h", "h"}, chess.A2},
+		{BlackDirection, []string{"up"}, chess.E1},
+		{BlackDirection, []string{"k", "k"}, chess.E1},
+		{BlackDirection, []string{"down", "left"}, chess.F3},
+		{BlackDirection, []string{"j", "l", "l", "l", "l", "l"}, chess.A3},
+	}
+	for _, tt := range tests {
+		m := New("")
+		m.newGame(HumanOpponent)
+		m.mode = GameMode
+		m.boardDirection = tt.direction
+		m.focusBoard()
+		for _, k := range tt.keys {
+			boardKey(m, k)
+		}
+		if m.cursor != tt.want {
+			t.Errorf("direction %d, %v: cursor on %s, want %s", tt.direction, tt.keys, m.cursor, tt.want)
+		}
+	}
+}
+
+func TestArrowFocusesBoard(t *testing.T) {
+	m := New("")
+	m.newGame(HumanOpponent)
+	m.mode = GameMode
+
+	m.nextMoveField.SetValue("N")
+	boardKey(m, "up")
+	if m.boardFocus {
+		t.Error("an arrow key took the focus from a half typed move")
+	}
+	m.nextMoveField.SetValue("")
+	boardKey(m, "up")
+	if !m.boardFocus || m.cursor != chess.E3 {
+		t.Errorf("an arrow key with nothing typed left focus %v, cursor on %s", m.boardFocus, m.cursor)
+	}
+	boardKey(m, "n")
+	if m.boardFocus {
+		t.Error("typing did not hand the move back to the move field")
+	}
+}
+
+func TestSelectionText(t *testing.T) {
+	tests := []struct {
+		fen      string
+		selected chess.Square
+		cursor   chess.Square
+		san      string
+		lan      string
+		uci      string
+	}{
+		{"", chess.G1, chess.G1, "N", "Ng1", "g1"},
+		{"", chess.G1, chess.F3, "Nf3", "Ng1f3", "g1f3"},
+		{"", chess.G1, chess.G3, "N", "Ng1", "g1"},
+		{"", chess.E2, chess.E2, "e", "e2", "e2"},
+		{"", chess.E2, chess.E4, "e4", "e2e4", "e2e4"},
+		{"1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1", chess.A7, chess.B8, "axb8=Q+", "a7xb8=Q+", "a7b8q"},
+	}
+	for _, tt := range tests {
+		for n, want := range []string{tt.san, tt.lan, tt.uci} {
+			m := New(tt.fen)
+			m.newGame(HumanOpponent)
+			m.mode = GameMode
+			m.setNotation(notation(n))
+			m.focusBoard()
+			m.cursor = tt.cursor
+			m.selectSquare(tt.selected)
+			if got := m.nextMoveField.Value(); got != want {
+				t.Errorf("%s from %s to %s: move field shows %q, want %q", notation(n), tt.selected, tt.cursor, got, want)
+			}
+		}
+	}
+}
+
+func TestPickSquare(t *testing.T) {
+	m := New("")
+	m.newGame(HumanOpponent)
+	m.mode = GameMode
+	m.focusBoard()
+
+	// Picking a square with nothing of the side to move does nothing.
+	m.cursor = chess.E4
+	m.pickSquare()
+	if m.selected != chess.NoSquare || m.err == nil {
+		t.Errorf("picking empty e4 selected %s with error %v", m.selected, m.err)
+	}
+
+	m.cursor = chess.E2
+	m.pickSquare()
+	if m.selected != chess.E2 {
+		t.Fatalf("picking e2 selected %s", m.selected)
+	}
+	m.cursor = chess.E4
+	m.pickSquare()
+	if moves := m.game.Moves(); len(moves) != 1 || moves[0].String() != "e2e4" {
+		t.Errorf("game has moves %v, want e2e4", moves)
+	}
+	if m.selected != chess.NoSquare {
+		t.Errorf("%s is still selected after the move", m.selected)
+	}
+}
+
+func TestPickSquarePromotion(t *testing.T) {
+	m := New("1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	m.newGame(HumanOpponent)
+	m.mode = GameMode
+	m.focusBoard()
+
+	m.cursor = chess.A7
+	m.pickSquare()
+	m.cursor = chess.B8
+	m.pickSquare()
+	if len(m.game.Moves()) != 0 {
+		t.Fatalf("a move was played without picking the promotion: %v", m.game.Moves())
+	}
+	if m.selected != chess.NoSquare {
+		t.Errorf("%s is still selected with the picker open", m.selected)
+	}
+	var got []chess.PieceType
+	for _, mov := range m.promotionMoves {
+		if mov.S1() != chess.A7 || mov.S2() != chess.B8 {
+			t.Errorf("the picker offers %s, which is not a7 to b8", mov.String())
+		}
+		got = append(got, mov.Promo())
+	}
+	if len(got) != len(promotionPieces) {
+		t.Fatalf("the picker offers %v, want %v", got, promotionPieces)
+	}
+	for i, pt := range promotionPieces {
+		if got[i] != pt {
+			t.Errorf("the picker offers %v, want %v", got, promotionPieces)
+			break
+		}
+	}
+}
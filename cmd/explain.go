@@ -0,0 +1,333 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+var (
+	sanRegex    = regexp.MustCompile(`^([KQRBN]?)([a-h]?)([1-8]?)(x?)([a-h][1-8])(=?[QRBNqrbn])?[+#!?]*$`)
+	lanRegex    = regexp.MustCompile(`^([KQRBN]?)([a-h][1-8])[-x]?([a-h][1-8])(=?[QRBNqrbn])?[+#!?]*$`)
+	castleRegex = regexp.MustCompile(`^(?:O-O(-O)?|0-0(-0)?)[+#]?$`)
+)
+
+var notationExamples = []string{
+	"e4, Nf3, exd5, O-O or e8=Q",
+	"e2e4, Ng1f3, e4xd5, O-O or e7e8=Q",
+	"e2e4, g1f3, e4d5 or e7e8q",
+}
+
+// moveQuery is what a learner asked for, parsed from any notation. Fields
+// the input left out are NoPieceType, NoSquare or empty.
+type moveQuery struct {
+	piece    chess.PieceType
+	from     chess.Square
+	fromFile string
+	fromRank string
+	to       chess.Square
+	promo    chess.PieceType
+}
+
+func pieceName(pt chess.PieceType) string {
+	switch pt {
+	case chess.King:
+		return "king"
+	case chess.Queen:
+		return "queen"
+	case chess.Rook:
+		return "rook"
+	case chess.Bishop:
+		return "bishop"
+	case chess.Knight:
+		return "knight"
+	case chess.Pawn:
+		return "pawn"
+	}
+	return "piece"
+}
+
+func (q moveQuery) matches(pos *chess.Position, mov *chess.Move) bool {
+	p := pos.Board().Piece(mov.S1())
+	if q.piece != chess.NoPieceType && p.Type() != q.piece {
+		return false
+	}
+	if q.from != chess.NoSquare && mov.S1() != q.from {
+		return false
+	}
+	if q.fromFile != "" && mov.S1().File().String() != q.fromFile {
+		return false
+	}
+	if q.fromRank != "" && mov.S1().Rank().String() != q.fromRank {
+		return false
+	}
+	if q.promo != chess.NoPieceType && mov.Promo() != q.promo {
+		return false
+	}
+	return mov.S2() == q.to
+}
+
+func (q moveQuery) filter(pos *chess.Position) []*chess.Move {
+	var moves []*chess.Move
+	for _, mov := range pos.ValidMoves() {
+		if q.matches(pos, mov) {
+			moves = append(moves, mov)
+		}
+	}
+	return moves
+}
+
+func parsePromo(s string) chess.PieceType {
+	return toPieceType(strings.ToUpper(strings.TrimPrefix(s, "=")))
+}
+
+// decodeMove reads input as a move in the current notation. The chess
+// package happily drops squares it cannot make sense of, reading g1f3 as
+// f3, so long algebraic input must also name the square the move is from.
+func (m *Model) decodeMove(input string) (*chess.Move, error) {
+	pos := m.game.Position()
+	mov, err := m.notation.encoding().Decode(pos, input)
+	if err != nil {
+		return nil, err
+	}
+	if m.notation == LANNotation {
+		parts := lanRegex.FindStringSubmatch(input)
+		if parts != nil && toSquare(parts[2]) != mov.S1() {
+			return nil, fmt.Errorf("%s does not start from %s", input, parts[2])
+		}
+	}
+	// UCI moves are decoded without looking at the position, so a pawn
+	// push to the last rank comes back without its promotion.
+	for _, valid := range pos.ValidMoves() {
+		if valid.S1() == mov.S1() && valid.S2() == mov.S2() && valid.Promo() == mov.Promo() {
+			return valid, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a legal move", input)
+}
+
+// explainMove works out why input was rejected as a move in the current
+// position, in terms a learner can act on.
+func (m *Model) explainMove(input string) error {
+	pos := m.game.Position()
+	turn := pos.Turn()
+
+	if input == "" {
+		return errors.New("Type a move first")
+	}
+
+	if parts := castleRegex.FindStringSubmatch(input); parts != nil && m.notation != UCINotation {
+		side := chess.KingSide
+		if parts[1] != "" || parts[2] != "" {
+			side = chess.QueenSide
+		}
+		if !pos.CastleRights().CanCastle(turn, side) {
+			return errors.New("You can no longer castle that way: the king or that rook has already moved")
+		}
+		return errors.New("You cannot castle out of, through or into check, or with pieces in the way")
+	}
+
+	q, err := m.parseQuery(input)
+	if err != nil {
+		return err
+	}
+
+	if q.from != chess.NoSquare {
+		p := pos.Board().Piece(q.from)
+		switch {
+		case p == chess.NoPiece:
+			return fmt.Errorf("There is no piece on %s", q.from)
+		case p.Color() != turn:
+			return fmt.Errorf("The %s %s on %s belongs to your opponent", strings.ToLower(p.Color().Name()), pieceName(p.Type()), q.from)
+		case q.piece != chess.NoPieceType && p.Type() != q.piece:
+			return fmt.Errorf("The piece on %s is a %s, not a %s", q.from, pieceName(p.Type()), pieceName(q.piece))
+		}
+	}
+
+	if p := pos.Board().Piece(q.to); p != chess.NoPiece && p.Color() == turn {
+		return fmt.Errorf("Your own %s is standing on %s", pieceName(p.Type()), q.to)
+	}
+
+	legal := q.filter(pos)
+	if len(legal) > 1 {
+		if legal[0].S1() == legal[1].S1() {
+			return fmt.Errorf("Promoting on %s needs a piece, e.g. %s", q.to, m.renderMove(*legal[0]))
+		}
+		var options []string
+		for _, mov := range legal {
+			options = append(options, m.renderMove(*mov))
+		}
+		return fmt.Errorf("Ambiguous: more than one %s can reach %s. Did you mean %s?", pieceName(q.piece), q.to, strings.Join(options, " or "))
+	}
+	if len(legal) == 1 {
+		return fmt.Errorf("In %s that move is written %s", m.notation, m.renderMove(*legal[0]))
+	}
+
+	if q.from == chess.NoSquare && !hasPiece(pos, chess.NewPiece(q.piece, turn)) {
+		return fmt.Errorf("You have no %s left", pieceName(q.piece))
+	}
+
+	// Taking the opponent's pieces off also clears any they stand in the
+	// way with, and those moves are blocked rather than illegal for check.
+	if relaxed := relaxedPosition(pos, q.to); relaxed != nil {
+		for _, mov := range q.filter(relaxed) {
+			if !pathClear(pos.Board(), mov.S1(), mov.S2()) {
+				continue
+			}
+			if inCheck(pos) {
+				return fmt.Errorf("You are in check, and %s does not get you out of it", input)
+			}
+			return fmt.Errorf("%s would leave your king in check", input)
+		}
+	}
+
+	if q.from != chess.NoSquare {
+		p := pos.Board().Piece(q.from)
+		return fmt.Errorf("The %s on %s cannot move to %s", pieceName(p.Type()), q.from, q.to)
+	}
+	return fmt.Errorf("None of your %ss can reach %s", pieceName(q.piece), q.to)
+}
+
+// parseQuery reads input in the current notation without regard for
+// whether it is legal.
+func (m *Model) parseQuery(input string) (moveQuery, error) {
+	q := moveQuery{from: chess.NoSquare}
+	if m.notation == SANNotation {
+		parts := sanRegex.FindStringSubmatch(input)
+		if parts == nil {
+			return q, fmt.Errorf("%q is not a move in SAN. Try %s", input, notationExamples[m.notation])
+		}
+		q.piece = toPieceType(parts[1])
+		if q.piece == chess.NoPieceType {
+			q.piece = chess.Pawn
+		}
+		q.fromFile = parts[2]
+		q.fromRank = parts[3]
+		q.to = toSquare(parts[5])
+		q.promo = parsePromo(parts[6])
+	} else {
+		parts := lanRegex.FindStringSubmatch(input)
+		if parts == nil || (m.notation == UCINotation && parts[1] != "") {
+			return q, fmt.Errorf("%q is not a move in %s. Try %s", input, m.notation, notationExamples[m.notation])
+		}
+		q.piece = toPieceType(parts[1])
+		if q.piece == chess.NoPieceType && m.notation == LANNotation {
+			q.piece = chess.Pawn
+		}
+		q.from = toSquare(parts[2])
+		q.to = toSquare(parts[3])
+		q.promo = parsePromo(parts[4])
+	}
+	return q, nil
+}
+
+func hasPiece(pos *chess.Position, piece chess.Piece) bool {
+	for _, p := range pos.Board().SquareMap() {
+		if p == piece {
+			return true
+		}
+	}
+	return false
+}
+
+// inCheck reports whether the side to move is in check, which the chess
+// package only exposes through the moves that lead to a position.
+func inCheck(pos *chess.Position) bool {
+	turn := pos.Turn()
+	board := pos.Board().SquareMap()
+	var king chess.Square = chess.NoSquare
+	for sq, p := range board {
+		if p == chess.NewPiece(chess.King, turn) {
+			king = sq
+		}
+	}
+	if king == chess.NoSquare {
+		return false
+	}
+
+	// Hand the move to the opponent and see whether any of their moves
+	// lands on our king.
+	fen := fmt.Sprintf("%s %s - - 0 1", pos.Board().String(), turn.Other())
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		return false
+	}
+	for _, mov := range chess.NewGame(opt).ValidMoves() {
+		if mov.S2() == king {
+			return true
+		}
+	}
+	return false
+}
+
+// pathClear reports whether the squares between from and to are empty on
+// b. Only moves along a rank, file or diagonal have any.
+func pathClear(b *chess.Board, from chess.Square, to chess.Square) bool {
+	df := int(to.File()) - int(from.File())
+	dr := int(to.Rank()) - int(from.Rank())
+	if df != 0 && dr != 0 && df != dr && df != -dr {
+		return true
+	}
+	stepF, stepR := sign(df), sign(dr)
+	f, r := int(from.File())+stepF, int(from.Rank())+stepR
+	for f != int(to.File()) || r != int(to.Rank()) {
+		if b.Piece(chess.NewSquare(chess.File(f), chess.Rank(r))) != chess.NoPiece {
+			return false
+		}
+		f, r = f+stepF, r+stepR
+	}
+	return true
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// relaxedPosition returns pos without the opponent's pieces, other than
+// their king and whatever stands on keep, so that every move which is only
+// illegal because of check becomes legal.
+func relaxedPosition(pos *chess.Position, keep chess.Square) *chess.Position {
+	turn := pos.Turn()
+	board := pos.Board().SquareMap()
+	for sq, p := range board {
+		if p.Color() != turn && p.Type() != chess.King && sq != keep {
+			delete(board, sq)
+		}
+	}
+
+	ep := "-"
+	if sq := pos.EnPassantSquare(); sq != chess.NoSquare {
+		ep = sq.String()
+	}
+	fen := fmt.Sprintf("%s %s - %s 0 1", chess.NewBoard(board).String(), turn, ep)
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		return nil
+	}
+	return chess.NewGame(opt).Position()
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import "testing"
+
+func TestExplainMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		input string
+		want  string
+	}{
+		{"blocked by a knight", "4k3/8/8/8/8/4n3/8/2B1K3 w - - 0 1", "Bg5", "None of your bishops can reach g5"},
+		{"blocked by a pawn", "4k3/8/8/8/p7/8/8/R3K3 w - - 0 1", "Ra8", "None of your rooks can reach a8"},
+		{"blocked by own piece", "4k3/8/8/8/8/8/P7/R3K3 w - - 0 1", "Ra8", "None of your rooks can reach a8"},
+		{"pinned", "4k3/4r3/8/8/8/8/4B3/4K3 w - - 0 1", "Bd3", "Bd3 would leave your king in check"},
+		{"pinned behind a blocker", "4k3/4r3/8/8/2p5/8/4B3/4K3 w - - 0 1", "Bb5", "None of your bishops can reach b5"},
+		{"in check", "4k3/4r3/8/8/8/8/8/R3K3 w - - 0 1", "Ra2", "You are in check, and Ra2 does not get you out of it"},
+		{"into check", "4k3/3r4/8/8/8/8/8/4K3 w - - 0 1", "Kd2", "Kd2 would leave your king in check"},
+		{"own piece on target", "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "Ra1", "Your own rook is standing on a1"},
+		{"no such piece", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", "Qd4", "You have no queen left"},
+		{"pawn push blocked", "4k3/8/8/8/8/4p3/4P3/4K3 w - - 0 1", "e4", "None of your pawns can reach e4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(tt.fen)
+			m.newGame(HumanOpponent)
+			err := m.explainMove(tt.input)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("explainMove(%q) = %v, want %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
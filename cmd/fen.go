@@ -0,0 +1,167 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+const fenFieldWidth = 2*columnWidth + margin*2
+
+var fenFieldNames = []string{"pieces", "side to move", "castling", "en passant", "halfmove clock", "move number"}
+
+// parseFEN checks that fen describes a position that can be played from and
+// returns it in canonical form. The move counters may be left out.
+func parseFEN(fen string) (string, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 4 {
+		fields = append(fields, "0", "1")
+	}
+	if len(fields) != len(fenFieldNames) {
+		return "", fmt.Errorf("A FEN has %d fields separated by spaces (%s), this one has %d",
+			len(fenFieldNames), strings.Join(fenFieldNames, ", "), len(fields))
+	}
+	fen = strings.Join(fields, " ")
+
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		return "", fmt.Errorf("Invalid FEN: %s", strings.TrimPrefix(err.Error(), "chess: fen "))
+	}
+	pos := chess.NewGame(opt).Position()
+
+	kings := map[chess.Piece]int{}
+	for sq, p := range pos.Board().SquareMap() {
+		if p.Type() == chess.King {
+			kings[p]++
+		}
+		if p.Type() == chess.Pawn && (sq.Rank() == chess.Rank1 || sq.Rank() == chess.Rank8) {
+			return "", fmt.Errorf("There is a pawn on %s, but pawns can never stand on the first or last rank", sq)
+		}
+	}
+	for _, king := range []chess.Piece{chess.WhiteKing, chess.BlackKing} {
+		if kings[king] != 1 {
+			return "", fmt.Errorf("%s must have exactly one king, not %d", king.Color().Name(), kings[king])
+		}
+	}
+
+	other := fmt.Sprintf("%s %s - - 0 1", pos.Board().String(), pos.Turn().Other())
+	if opt, err := chess.FEN(other); err == nil && inCheck(chess.NewGame(opt).Position()) {
+		return "", fmt.Errorf("%s is in check but it is %s's turn", pos.Turn().Other().Name(), strings.ToLower(pos.Turn().Name()))
+	}
+	if status := pos.Status(); status != chess.NoMethod {
+		return "", fmt.Errorf("That position is already over by %s", methodNames[status])
+	}
+	return fen, nil
+}
+
+func newFENField() textinput.Model {
+	field := textinput.New()
+	field.Placeholder = "FEN, or empty for the usual start"
+	field.CharLimit = 100
+	field.Width = fenFieldWidth
+	return field
+}
+
+func (m *Model) copyFEN() {
+	fen := m.game.FEN()
+	if err := clipboard.WriteAll(fen); err != nil {
+		m.notice = fen
+		m.err = errors.New("Could not reach the clipboard, the FEN is above")
+		return
+	}
+	m.err = nil
+	m.notice = "FEN copied"
+}
+
+func (m *Model) fenUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.err = nil
+			m.mode = MainMenuMode
+			return m, nil
+		case tea.KeyEnter:
+			input := strings.TrimSpace(m.fenField.Value())
+			if input == "" {
+				m.startFEN = ""
+			} else {
+				fen, err := parseFEN(input)
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.startFEN = fen
+			}
+			m.err = nil
+			m.gameFEN = m.startFEN
+			m.game = *chess.NewGame(m.gameOptions()...)
+			m.mode = MainMenuMode
+			return m, nil
+		}
+	}
+
+	m.fenField, cmd = m.fenField.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.err = nil
+	}
+	return m, cmd
+}
+
+func (m *Model) fenView() string {
+	var preview string
+	if fen, err := parseFEN(m.fenField.Value()); err == nil {
+		opt, _ := chess.FEN(fen)
+		preview = m.renderPosition(chess.NewGame(opt).Position(), nil)
+	}
+
+	form := lipgloss.JoinVertical(
+		lipgloss.Left,
+		"Load FEN",
+		"",
+		m.fenField.View(),
+	)
+	if m.err != nil {
+		form = lipgloss.JoinVertical(
+			lipgloss.Left,
+			form,
+			errorStyle.Copy().Width(fenFieldWidth).Render(m.err.Error()),
+		)
+	}
+	form = lipgloss.JoinVertical(lipgloss.Left, form, "", "enter load\nesc back")
+
+	return lipgloss.JoinVertical(
+		lipgloss.Center,
+		renderTitle(),
+		lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			columnStyle.Copy().Align(lipgloss.Center).Render(preview),
+			lipgloss.NewStyle().Margin(0, margin).Render(form),
+		),
+	)
+}
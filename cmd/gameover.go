@@ -0,0 +1,300 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bubble-chess/engine"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+const analysisMoveTime = 500 * time.Millisecond
+
+var methodNames = map[chess.Method]string{
+	chess.Checkmate:            "checkmate",
+	chess.Resignation:          "resignation",
+	chess.DrawOffer:            "agreement",
+	chess.Stalemate:            "stalemate",
+	chess.ThreefoldRepetition:  "threefold repetition",
+	chess.FivefoldRepetition:   "fivefold repetition",
+	chess.FiftyMoveRule:        "the fifty-move rule",
+	chess.SeventyFiveMoveRule:  "the seventy-five-move rule",
+	chess.InsufficientMaterial: "insufficient material",
+}
+
+var outcomeStyle = lipgloss.NewStyle().
+	Bold(true).
+	MarginBottom(1)
+
+var noticeStyle = lipgloss.NewStyle().
+	Width(width - columnWidth - margin*2)
+
+var pgnStyle = lipgloss.NewStyle().
+	Margin(1, margin).
+	Width(width - margin*2)
+
+func gameOverMenuItems() []MenuItem {
+	return []MenuItem{
+		{
+			title:  "Rematch",
+			action: func() tea.Msg { return GameMsg(GameRematch) },
+		},
+		{
+			title:  "Analyze",
+			action: func() tea.Msg { return GameMsg(GameAnalyze) },
+		},
+		{
+			title:  "Save PGN",
+			action: func() tea.Msg { return GameMsg(GameSave) },
+		},
+		{
+			title:  "Main menu",
+			action: exitGame,
+		},
+	}
+}
+
+func (m *Model) describeOutcome() string {
+	var result string
+	switch m.outcome() {
+	case chess.WhiteWon:
+		result = "White wins"
+	case chess.BlackWon:
+		result = "Black wins"
+	case chess.Draw:
+		result = "Draw"
+	default:
+		return "Game in progress"
+	}
+	if m.flagged != chess.NoColor {
+		if m.outcome() == chess.Draw {
+			return fmt.Sprintf("Draw, %s ran out of time but %s cannot mate", m.flagged.Name(), m.flagged.Other().Name())
+		}
+		return result + " on time"
+	}
+	if name, ok := methodNames[m.game.Method()]; ok {
+		result += " by " + name
+	}
+	return result
+}
+
+// claimDraws ends the game once a threefold repetition or the fifty-move
+// rule allows it. The chess package only ends the game by itself at five
+// repetitions or seventy-five moves.
+func (m *Model) claimDraws() {
+	for _, method := range m.game.EligibleDraws() {
+		if method == chess.ThreefoldRepetition || method == chess.FiftyMoveRule {
+			m.game.Draw(method)
+			return
+		}
+	}
+}
+
+func (m *Model) rematch() tea.Cmd {
+	if m.opponent == HumanOpponent {
+		m.newGame(HumanOpponent)
+		return m.beginGame()
+	}
+	return m.startVsComputer(m.cpuColor)
+}
+
+func (m *Model) gameOverUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			return m, exitGame
+		case tea.KeyEnter:
+			return m, m.gameOverItems[m.gameOverCursor].action
+		case tea.KeyDown:
+			m.gameOverCursor = wrapCursor(m.gameOverCursor, 1, len(m.gameOverItems))
+		case tea.KeyUp:
+			m.gameOverCursor = wrapCursor(m.gameOverCursor, -1, len(m.gameOverItems))
+		}
+		return m, nil
+	case GameMsg:
+		switch msg {
+		case GameRematch:
+			return m, m.rematch()
+		case GameAnalyze:
+			m.cpu.SetLevel(engine.MaxLevel)
+			m.mode = AnalyzeMode
+			return m, m.review(len(m.game.Moves()))
+		case GameSave:
+			m.saveGame()
+		case GameExit:
+			m.err = nil
+			m.mode = MainMenuMode
+		}
+	}
+
+	return m, nil
+}
+
+// review shows the position after ply half-moves of the finished game and
+// starts the engine looking at it.
+func (m *Model) review(ply int) tea.Cmd {
+	moves := m.game.Moves()
+	if ply < 0 || ply > len(moves) {
+		return nil
+	}
+	m.stopSearch()
+	m.reviewPly = ply
+	m.analysis = ""
+	m.highlightsBoard = 0
+
+	pos := m.game.Positions()[ply]
+	if pos.Status() != chess.NoMethod {
+		m.analysis = m.describeOutcome()
+		return nil
+	}
+	fen, err := chess.FEN(pos.String())
+	if err != nil {
+		return nil
+	}
+	game := chess.NewGame(fen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
+	m.thinking = true
+	cpu := m.cpu
+	return func() tea.Msg {
+		defer cancel()
+		result, err := cpu.Search(ctx, game, engine.Limits{MoveTime: analysisMoveTime})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return analysisMsg{ply: ply}
+		}
+		return analysisMsg{ply: ply, result: result}
+	}
+}
+
+func (m *Model) analyzeUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.stopSearch()
+			m.highlightsBoard = 0
+			m.mode = GameOverMode
+		case tea.KeyLeft, tea.KeyUp:
+			return m, m.review(m.reviewPly - 1)
+		case tea.KeyRight, tea.KeyDown:
+			return m, m.review(m.reviewPly + 1)
+		case tea.KeyHome:
+			return m, m.review(0)
+		case tea.KeyEnd:
+			return m, m.review(len(m.game.Moves()))
+		}
+		if m.keys.lookup(msg) == FlipKey {
+			m.flipBoard()
+		}
+	case analysisMsg:
+		if msg.ply != m.reviewPly {
+			return m, nil
+		}
+		m.thinking = false
+		m.analysis = m.describeAnalysis(m.game.Positions()[msg.ply], msg.result)
+	}
+
+	return m, nil
+}
+
+func (m *Model) describeAnalysis(pos *chess.Position, r engine.Result) string {
+	if r.Move == nil {
+		return ""
+	}
+	score := r.Score
+	if pos.Turn() == chess.Black {
+		score = -score
+	}
+
+	var eval string
+	if mate, n := engine.IsMate(r.Score); mate {
+		if pos.Turn() == chess.Black {
+			n = -n
+		}
+		eval = fmt.Sprintf("#%d", n)
+	} else {
+		eval = fmt.Sprintf("%+.2f", float64(score)/100)
+	}
+	return fmt.Sprintf("Best %s\nEval %s\nDepth %d", m.notation.encoding().Encode(pos, r.Move), eval, r.Depth)
+}
+
+func (m *Model) reviewCaption() string {
+	moves := m.game.Moves()
+	if m.reviewPly == 0 {
+		return "Start"
+	}
+	pos := m.game.Positions()[m.reviewPly-1]
+	number := moveNumber(pos)
+	if pos.Turn() == chess.White {
+		return fmt.Sprintf("%d. %s", number, m.notation.encoding().Encode(pos, moves[m.reviewPly-1]))
+	}
+	return fmt.Sprintf("%d... %s", number, m.notation.encoding().Encode(pos, moves[m.reviewPly-1]))
+}
+
+func (m *Model) gameOverView() string {
+	status := outcomeStyle.Render(m.describeOutcome())
+	if m.err != nil {
+		status = lipgloss.JoinVertical(lipgloss.Left, status, errorStyle.Copy().Width(width-columnWidth-margin*2).Render(m.err.Error()))
+	} else if m.notice != "" {
+		status = lipgloss.JoinVertical(lipgloss.Left, status, noticeStyle.Render(m.notice))
+	}
+
+	mainContent := lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		columnStyle.Copy().Align(lipgloss.Center).Render(m.RenderBoard()),
+		lipgloss.JoinVertical(
+			lipgloss.Left,
+			status,
+			renderMenu(m.gameOverItems, m.gameOverCursor),
+		),
+	)
+
+	return lipgloss.JoinVertical(
+		lipgloss.Top,
+		mainContent,
+		pgnStyle.Render(m.movetext()),
+	)
+}
+
+func (m *Model) analyzeView() string {
+	analysis := m.analysis
+	if m.thinking {
+		analysis = "Thinking..."
+	}
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		columnStyle.Copy().Align(lipgloss.Center).Render(m.renderPosition(m.game.Positions()[m.reviewPly], lastMove(m.game.Moves(), m.reviewPly))),
+		columnStyle.Render(m.reviewCaption()+"\n\n"+analysis),
+		columnStyle.Copy().MarginRight(0).Render("esc back\n^C quit\n←/→ step\nhome/end jump\n"+m.keys.label(FlipKey)+" flip"),
+	)
+}
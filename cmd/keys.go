@@ -0,0 +1,137 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type keyAction int
+
+const (
+	FlipKey = iota
+	NotationKey
+	ThemeKey
+	BoardKey
+	UndoKey
+	CopyFENKey
+	SavePGNKey
+	AutoFlipKey
+)
+
+const NoKey keyAction = -1
+
+var keyActionNames = []string{"flip", "notation", "theme", "board", "undo", "copy-fen", "save-pgn", "autoflip"}
+
+var defaultKeys = keyMap{"ctrl+f", "ctrl+n", "ctrl+g", "ctrl+b", "ctrl+z", "ctrl+y", "ctrl+s", "ctrl+r"}
+
+// Keys can be bound to control and alt combinations or function keys,
+// which leaves every plain key free for typing moves. Terminals send ^I,
+// ^M and ^[ as tab, enter and esc, and ^C always quits.
+var (
+	bindableKeyRegex = regexp.MustCompile(`^(ctrl\+[a-z]|alt\+.+|f([1-9]|1[0-9]|20))$`)
+	reservedKeys     = []string{"ctrl+c", "ctrl+i", "ctrl+m"}
+)
+
+// keyMap holds the key bound to each action, indexed by keyAction.
+type keyMap []string
+
+func (a keyAction) String() string {
+	return keyActionNames[a]
+}
+
+func parseKeyAction(name string) (keyAction, error) {
+	for idx, n := range keyActionNames {
+		if strings.EqualFold(name, n) {
+			return keyAction(idx), nil
+		}
+	}
+	return NoKey, fmt.Errorf("unknown key action %q, expected one of %s", name, strings.Join(keyActionNames, ", "))
+}
+
+// lookup returns the action msg is bound to, or NoKey.
+func (k keyMap) lookup(msg tea.KeyMsg) keyAction {
+	for idx, key := range k {
+		if key == msg.String() {
+			return keyAction(idx)
+		}
+	}
+	return NoKey
+}
+
+func checkKey(key string) error {
+	bindable := bindableKeyRegex.MatchString(key)
+	for _, reserved := range reservedKeys {
+		bindable = bindable && key != reserved
+	}
+	if !bindable {
+		return fmt.Errorf("%s cannot be bound, use a ctrl, alt or function key", key)
+	}
+	return nil
+}
+
+// bind returns a copy of k with key bound to action.
+func (k keyMap) bind(action keyAction, key string) (keyMap, error) {
+	if err := checkKey(key); err != nil {
+		return k, err
+	}
+	for idx, other := range k {
+		if other == key && keyAction(idx) != action {
+			return k, fmt.Errorf("%s is already bound to %s", key, keyAction(idx))
+		}
+	}
+	bound := append(keyMap{}, k...)
+	bound[action] = key
+	return bound, nil
+}
+
+// bindKeys returns the default keys with the given bindings of action
+// names to keys in their place.
+func bindKeys(bindings map[string]string) (keyMap, error) {
+	keys := append(keyMap{}, defaultKeys...)
+	for name, key := range bindings {
+		action, err := parseKeyAction(name)
+		if err != nil {
+			return defaultKeys, err
+		}
+		if err := checkKey(key); err != nil {
+			return defaultKeys, err
+		}
+		keys[action] = key
+	}
+	for a := range keys {
+		for b := a + 1; b < len(keys); b++ {
+			if keys[a] == keys[b] {
+				return defaultKeys, fmt.Errorf("%s is bound to both %s and %s", keys[a], keyAction(a), keyAction(b))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// label is how the key for action is shown in the help, ^F for ctrl+f.
+func (k keyMap) label(action keyAction) string {
+	key := k[action]
+	if letter := strings.TrimPrefix(key, "ctrl+"); len(letter) == 1 {
+		return "^" + strings.ToUpper(letter)
+	}
+	return key
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The game view is the board followed by a column with the captures,
+// clocks, moves and move field, and one with the help. Those columns are
+// measured as drawn, since clocks, the promotion picker and messages make
+// them taller, and the guess list below them takes the footer.
+const (
+	maxBoardScale = 3
+	footerHeight  = margin*2 + 2
+)
+
+var boardColumnStyle = lipgloss.NewStyle().
+	MarginLeft(margin)
+
+var tooSmallStyle = lipgloss.NewStyle().
+	Margin(1, margin)
+
+func boardWidth(scale int) int {
+	return 16*scale + 4
+}
+
+func boardHeight(scale int) int {
+	return 8*scale + 2
+}
+
+// wideSize is the room the game view needs with the board, the moves
+// column and the help side by side.
+func wideSize(scale int, moves string, help string) (int, int) {
+	w := margin + boardWidth(scale) + lipgloss.Width(moves) + lipgloss.Width(help)
+	h := max(boardHeight(scale), max(lipgloss.Height(moves), lipgloss.Height(help)))
+	return w, h + footerHeight
+}
+
+// stackedSize is the room it needs with the moves under the board.
+func stackedSize(scale int, moves string, help string) (int, int) {
+	w := max(margin+boardWidth(scale), lipgloss.Width(moves)) + lipgloss.Width(help)
+	h := max(boardHeight(scale)+lipgloss.Height(moves), lipgloss.Height(help))
+	return w, h + footerHeight
+}
+
+// resize keeps the terminal's size for the game view to be laid out in.
+func (m *Model) resize(w int, h int) {
+	m.termWidth, m.termHeight = w, h
+}
+
+// layout picks the biggest board that fits the terminal beside the moves
+// and help columns as drawn. With the same board, the moves go next to it
+// rather than under it. It reports false when neither layout fits.
+func (m *Model) layout(moves string, help string) bool {
+	if m.termWidth == 0 && m.termHeight == 0 {
+		// The size is not known yet.
+		m.boardScale, m.stacked = 1, false
+		return true
+	}
+	for scale := maxBoardScale; scale >= 1; scale-- {
+		m.boardScale = scale
+		if needW, needH := wideSize(scale, moves, help); needW <= m.termWidth && needH <= m.termHeight {
+			m.stacked = false
+			return true
+		}
+		if needW, needH := stackedSize(scale, moves, help); needW <= m.termWidth && needH <= m.termHeight {
+			m.stacked = true
+			return true
+		}
+	}
+	m.boardScale, m.stacked = 1, false
+	return false
+}
+
+// squareScale is how many rows high the board's squares are drawn. Only
+// the game view has the room for bigger ones.
+func (m *Model) squareScale() int {
+	if m.mode != GameMode || m.boardScale < 1 {
+		return 1
+	}
+	return m.boardScale
+}
+
+// tooSmallView stands in for the game view when neither layout fits, so
+// the board never comes out garbled.
+func (m *Model) tooSmallView(moves string, help string) string {
+	wideW, wideH := wideSize(1, moves, help)
+	stackedW, stackedH := stackedSize(1, moves, help)
+	return tooSmallStyle.Render(fmt.Sprintf(
+		"Terminal too small\n\nNeeds %d×%d or %d×%d,\nhas %d×%d.\n\nesc back  ^C quit",
+		wideW, wideH, stackedW, stackedH, m.termWidth, m.termHeight,
+	))
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+func TestGameViewFitsTerminal(t *testing.T) {
+	setups := map[string]func(m *Model){
+		"plain": func(m *Model) {},
+		"timed": func(m *Model) {
+			m.setTimeControl(mustParseTimeControl("", "5+3"))
+			m.startClock()
+		},
+		"timed with promotion": func(m *Model) {
+			m.setTimeControl(mustParseTimeControl("", "5+3"))
+			m.startClock()
+			var choices []chess.Move
+			for _, mov := range m.game.ValidMoves() {
+				if mov.S1() == chess.A7 {
+					choices = append(choices, *mov)
+				}
+			}
+			m.openPromotion(choices)
+		},
+		"error": func(m *Model) {
+			m.err = errors.New("This message is long enough to wrap over several lines of the moves column")
+		},
+	}
+	sizes := [][2]int{{64, 16}, {43, 26}, {80, 24}, {100, 30}, {120, 40}, {200, 60}}
+	for name, setup := range setups {
+		for _, size := range sizes {
+			m := New("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+			m.newGame(HumanOpponent)
+			setup(m)
+			m.resize(size[0], size[1])
+			view := m.gameView()
+			if w, h := lipgloss.Width(view), lipgloss.Height(view); w > size[0] || h > size[1] {
+				t.Errorf("%s at %d×%d: drew %d×%d", name, size[0], size[1], w, h)
+			}
+		}
+	}
+}
+
+func TestGameViewGrowsBoard(t *testing.T) {
+	m := New("")
+	m.newGame(HumanOpponent)
+	m.resize(200, 60)
+	m.gameView()
+	if m.boardScale != maxBoardScale || m.stacked {
+		t.Errorf("at 200×60 the board is scaled %d, stacked %v", m.boardScale, m.stacked)
+	}
+	m.resize(43, 40)
+	m.gameView()
+	if !m.stacked {
+		t.Errorf("at 43×40 the moves are not under the board")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+// boardMark is what a square is picked out for beneath the cursor and the
+// highlights. Where marks overlap, the later one is drawn.
+type boardMark int
+
+const (
+	NoMark boardMark = iota
+	LastMoveMark
+	CheckMark
+	DestinationMark
+	CaptureMark
+)
+
+// lastMove returns the move that led to the position after ply half-moves,
+// or nil at the start.
+func lastMove(moves []*chess.Move, ply int) *chess.Move {
+	if ply < 1 || ply > len(moves) {
+		return nil
+	}
+	return moves[ply-1]
+}
+
+func kingSquare(b *chess.Board, color chess.Color) chess.Square {
+	for sq, p := range b.SquareMap() {
+		if p.Type() == chess.King && p.Color() == color {
+			return sq
+		}
+	}
+	return chess.NoSquare
+}
+
+// boardMarks returns the mark on each square of pos, which last led to.
+// The selected piece's moves are only marked on the game's own position.
+func (m *Model) boardMarks(pos *chess.Position, last *chess.Move) [64]boardMark {
+	var marks [64]boardMark
+	if last != nil {
+		marks[last.S1()] = LastMoveMark
+		marks[last.S2()] = LastMoveMark
+		if king := kingSquare(pos.Board(), pos.Turn()); last.HasTag(chess.Check) && king != chess.NoSquare {
+			marks[king] = CheckMark
+		}
+	}
+	if m.selected != chess.NoSquare && pos == m.game.Position() {
+		for _, mov := range m.movesFrom(m.selected) {
+			if mov.HasTag(chess.Capture) || mov.HasTag(chess.EnPassant) {
+				marks[mov.S2()] = CaptureMark
+			} else {
+				marks[mov.S2()] = DestinationMark
+			}
+		}
+	}
+	return marks
+}
+
+// markStyles returns the style each mark is drawn in, by mark.
+func (m *Model) markStyles() []lipgloss.Style {
+	colors := []lipgloss.TerminalColor{
+		NoMark:          lipgloss.NoColor{},
+		LastMoveMark:    m.theme.lastMove,
+		CheckMark:       m.theme.check,
+		DestinationMark: m.theme.destination,
+		CaptureMark:     m.theme.capture,
+	}
+	styles := make([]lipgloss.Style, len(colors))
+	for mark, color := range colors {
+		styles[mark] = lipgloss.NewStyle().Background(color)
+	}
+	return styles
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+// capturableTypes are the pieces that can be captured, most valuable
+// first, with what they are worth.
+var (
+	capturableTypes = []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn}
+	pieceValues     = map[chess.PieceType]int{chess.Queen: 9, chess.Rook: 5, chess.Bishop: 3, chess.Knight: 3, chess.Pawn: 1}
+)
+
+var materialStyle = lipgloss.NewStyle().
+	MaxWidth(columnWidth)
+
+// captured returns the pieces of color on start that are missing from b,
+// most valuable first. A piece beyond those on start came from a pawn that
+// promoted, so that pawn is not counted as missing.
+func captured(start *chess.Board, b *chess.Board, color chess.Color) []chess.Piece {
+	missing := map[chess.PieceType]int{}
+	for _, p := range start.SquareMap() {
+		if p.Color() == color {
+			missing[p.Type()]++
+		}
+	}
+	for _, p := range b.SquareMap() {
+		if p.Color() == color {
+			missing[p.Type()]--
+		}
+	}
+	for _, pt := range capturableTypes {
+		if pt != chess.Pawn && missing[pt] < 0 {
+			missing[chess.Pawn] += missing[pt]
+		}
+	}
+	var pieces []chess.Piece
+	for _, pt := range capturableTypes {
+		for n := 0; n < missing[pt]; n++ {
+			pieces = append(pieces, toPiece(pt, color))
+		}
+	}
+	return pieces
+}
+
+// material is what the pieces of color on b are worth, in pawns.
+func material(b *chess.Board, color chess.Color) int {
+	total := 0
+	for _, p := range b.SquareMap() {
+		if p.Color() == color {
+			total += pieceValues[p.Type()]
+		}
+	}
+	return total
+}
+
+// renderCaptures returns the pieces color has taken since start, followed
+// by how far ahead it is in material on b when it is.
+func (m *Model) renderCaptures(start *chess.Board, b *chess.Board, color chess.Color) string {
+	var glyphs []string
+	for _, p := range captured(start, b, color.Other()) {
+		glyphs = append(glyphs, m.pieces.glyph(p))
+	}
+	text := strings.Join(glyphs, "")
+	if lead := material(b, color) - material(b, color.Other()); lead > 0 {
+		text += fmt.Sprintf(" +%d", lead)
+	}
+	return materialStyle.Render(strings.TrimSpace(text))
+}
+
+// renderMaterial returns the captures of the sides at the top and the
+// bottom of the board.
+func (m *Model) renderMaterial() (top string, bottom string) {
+	// Games set up from a FEN start without some of the pieces.
+	start := m.game.Positions()[0].Board()
+	b := m.game.Position().Board()
+	if m.boardDirection == WhiteDirection {
+		return m.renderCaptures(start, b, chess.Black), m.renderCaptures(start, b, chess.White)
+	}
+	return m.renderCaptures(start, b, chess.White), m.renderCaptures(start, b, chess.Black)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestCaptured(t *testing.T) {
+	tests := []struct {
+		fen   string
+		moves []string
+		white string
+		black string
+	}{
+		{"", nil, "", ""},
+		{"", []string{"e4", "d5", "exd5", "Qxd5"}, "p", "p"},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", nil, "", ""},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", []string{"Ra8+", "Kd7", "Rb8", "Kc7", "Rb1"}, "", ""},
+		{"r3k3/8/8/8/8/8/8/R3K3 w - - 0 1", []string{"Rxa8+"}, "", "r"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", []string{"a8=Q+"}, "", ""},
+		{"1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", []string{"axb8=N"}, "", "r"},
+		{"1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1", []string{"a8=Q", "Rxa8"}, "p", ""},
+	}
+	for _, tt := range tests {
+		var opts []func(*chess.Game)
+		if tt.fen != "" {
+			opt, err := chess.FEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts = append(opts, opt)
+		}
+		game := chess.NewGame(opts...)
+		for _, mv := range tt.moves {
+			if err := game.MoveStr(mv); err != nil {
+				t.Fatalf("%s: %s: %v", tt.fen, mv, err)
+			}
+		}
+		start := game.Positions()[0].Board()
+		b := game.Position().Board()
+		for _, side := range []struct {
+			color chess.Color
+			want  string
+		}{{chess.White, tt.white}, {chess.Black, tt.black}} {
+			got := ""
+			for _, p := range captured(start, b, side.color) {
+				got += p.Type().String()
+			}
+			if got != side.want {
+				t.Errorf("%q after %v: captured %s pieces %q, want %q", tt.fen, tt.moves, side.color.Name(), got, side.want)
+			}
+		}
+	}
+}
@@ -17,12 +17,18 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/bits"
 	"math/rand"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
+
+	"bubble-chess/engine"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -34,9 +40,20 @@ import (
 
 type GameMsg int
 type programMode int
+type opponent uint8
 type direction uint8
 type bitboard uint64
 type errMsg error
+type cpuMoveMsg struct {
+	id     int
+	result engine.Result
+}
+type difficultyMsg int
+type sideMsg chess.Color
+type analysisMsg struct {
+	ply    int
+	result engine.Result
+}
 type MenuItem struct {
 	title  string
 	action tea.Cmd
@@ -48,18 +65,92 @@ type Model struct {
 	menuItems  []MenuItem
 	menuCursor int
 
+	difficultyItems  []MenuItem
+	difficultyCursor int
+	difficulty       int
+
+	sideItems  []MenuItem
+	sideCursor int
+
+	control           timeControl
+	timeControls      []timeControl
+	timeControlItems  []MenuItem
+	timeControlCursor int
+
+	gameOverItems  []MenuItem
+	gameOverCursor int
+	reviewPly      int
+	analysis       string
+	notice         string
+
+	fenField textinput.Model
+
+	pathField   textinput.Model
+	pgnPath     string
+	pgnGames    []pgnEntry
+	pgnView     []int
+	pgnCursor   int
+	pgnSort     int
+	pgnSortDesc bool
+	filterField textinput.Model
+	fenQuery    string
+	fenMatches  map[int]bool
+	replaying   *chess.Game
+	replayPly   int
+
 	credits       []creditVisual
 	creditsCursor int
 
+	config          config
+	settings        config
+	configPath      string
+	keys            keyMap
+	settingsCursor  int
+	settingsCapture bool
+
 	pastMovesView   viewport.Model
 	nextMoveField   textinput.Model
 	game            chess.Game
+	startFEN        string
+	gameFEN         string
+	notation        notation
+	pieces          pieceSet
+	theme           theme
+	themes          []theme
+	opponent        opponent
+	cpuColor        chess.Color
 	boardDirection  direction
+	autoFlip        bool
+	takebacks       bool
+	startedAt       time.Time
+	timeControl     string
+	annotator       string
+	pgnDir          string
 	highlightsBoard bitboard
+	boardFocus      bool
+	cursor          chess.Square
+	selected        chess.Square
 	guessList       []chess.Move
 	guessMenu       string
 	guessCursor     int
 	err             error
+
+	promotionMoves  []chess.Move
+	promotionCursor int
+
+	clock   *chessClock
+	clockID int
+	flagged chess.Color
+
+	termWidth  int
+	termHeight int
+	boardScale int
+	stacked    bool
+
+	cpu          engine.Engine
+	cancelSearch context.CancelFunc
+	thinking     bool
+	searchID     int
 }
 
 // 88888bo 888 888 88888bo 88888bo 888    d88888
@@ -93,12 +184,26 @@ const (
 	margin      = 1
 )
 
+const (
+	cpuMoveTime       = time.Second
+	defaultDifficulty = 2
+)
+
 const (
 	GameCPUTurn = iota
 	GameOver
 	GameStart
 	GameExit
 	GameViewCredits
+	GameChooseDifficulty
+	GameStartVsPlayer
+	GameRematch
+	GameAnalyze
+	GameSave
+	GameLoadFEN
+	GameOpenPGN
+	GameResume
+	GameSettings
 )
 
 const (
@@ -115,10 +220,25 @@ const (
 	BlackDirection
 )
 
+const (
+	ComputerOpponent = iota
+	HumanOpponent
+)
+
 const (
 	MainMenuMode = iota
 	GameMode
 	CreditsMode
+	DifficultyMode
+	SideMode
+	GameOverMode
+	AnalyzeMode
+	FENMode
+	OpenPGNMode
+	GameListMode
+	ReplayMode
+	TimeControlMode
+	SettingsMode
 )
 
 var rootCmd = &cobra.Command{
@@ -128,16 +248,73 @@ var rootCmd = &cobra.Command{
 It is build with Bubbletea and is intended
 to be feature complete by December 31 2023.`,
 
-	Run: func(cmd *cobra.Command, args []string) {
-		p := tea.NewProgram(New(""))
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, cfgPath, err := loadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+		configFlags(cmd, cfg)
+		// Past the flags, errors are not about how the command was used.
+		cmd.SilenceUsage = true
+
+		var fen string
+		if customStartFEN != "" {
+			if fen, err = parseFEN(customStartFEN); err != nil {
+				return err
+			}
+		}
+		m := New(fen)
+		if err := m.setDisplay(notationName, pieceSetName, themeName); err != nil {
+			return err
+		}
+		m.takebacks = !noTakebacks
+		m.pgnDir = pgnDir
+		m.annotator = annotator
+		tc, err := parseTimeControl(timeControlSpec)
+		if err != nil {
+			return err
+		}
+		m.setTimeControl(tc)
+		if err := m.applyConfig(cfg); err != nil {
+			return err
+		}
+		m.configPath = cfgPath
+		if enginePath != "" {
+			uci, err := engine.NewUCI(enginePath)
+			if err != nil {
+				return err
+			}
+			m.cpu = uci
+		}
+		defer m.cpu.Close()
 
-		if _, err := p.Run(); err != nil {
-			fmt.Printf("Alas, there's been an error: %v", err)
-			os.Exit(1)
+		// The game view is fitted to the window, and clicks can only be
+		// placed on the board when it is drawn from the top of the screen.
+		opts := []tea.ProgramOption{tea.WithAltScreen()}
+		if !noMouse {
+			opts = append(opts, tea.WithMouseCellMotion())
 		}
+		p := tea.NewProgram(m, opts...)
+
+		_, err = p.Run()
+		return err
 	},
 }
 
+var (
+	cfgFile         string
+	customStartFEN  string
+	enginePath      string
+	notationName    string
+	pieceSetName    string
+	themeName       string
+	noTakebacks     bool
+	pgnDir          string
+	annotator       string
+	timeControlSpec string
+	noMouse         bool
+)
+
 var (
 	white       = lipgloss.CompleteColor{TrueColor: "#FFFFFF", ANSI256: "15", ANSI: "15"}
 	black       = lipgloss.CompleteColor{TrueColor: "#000000", ANSI256: "0", ANSI: "0"}
@@ -145,6 +322,11 @@ var (
 	cyan        = lipgloss.CompleteColor{TrueColor: "#4DA5C9", ANSI256: "14", ANSI: "6"}
 	green       = lipgloss.CompleteColor{TrueColor: "#0dbc79", ANSI256: "2", ANSI: "2"}
 	brightgreen = lipgloss.CompleteColor{TrueColor: "#23d18b", ANSI256: "10", ANSI: "10"}
+	red         = lipgloss.CompleteColor{TrueColor: "#F14C4C", ANSI256: "9", ANSI: "1"}
+	yellow      = lipgloss.CompleteColor{TrueColor: "#E5E510", ANSI256: "11", ANSI: "3"}
+	blue        = lipgloss.CompleteColor{TrueColor: "#2472C8", ANSI256: "12", ANSI: "4"}
+	orange      = lipgloss.CompleteColor{TrueColor: "#E8912D", ANSI256: "208", ANSI: "3"}
+	grey        = lipgloss.CompleteColor{TrueColor: "#767676", ANSI256: "8", ANSI: "8"}
 )
 
 var (
@@ -207,6 +389,10 @@ var selectedMenuItemStyle = lipgloss.NewStyle().
 var menuListStyle = lipgloss.NewStyle().
 	MarginRight(4)
 
+var errorStyle = lipgloss.NewStyle().
+	Foreground(red).
+	Width(columnWidth)
+
 func contains(squares []chess.Square, s chess.Square) bool {
 	for i := range squares {
 		if s == squares[i] {
@@ -266,10 +452,14 @@ func renderTitle() (title string) {
 }
 
 func (m *Model) renderMenuItems() string {
+	return renderMenu(m.menuItems, m.menuCursor)
+}
+
+func renderMenu(items []MenuItem, cursor int) string {
 	var menu = ""
-	for idx, itm := range m.menuItems {
+	for idx, itm := range items {
 		baseItm := fmt.Sprintf(" %s ", itm.title)
-		if idx == m.menuCursor {
+		if idx == cursor {
 			menu += selectedMenuItemStyle.Render(baseItm)
 		} else {
 			menu += baseItm
@@ -279,16 +469,239 @@ func (m *Model) renderMenuItems() string {
 	return menuListStyle.Render(menu)
 }
 
+// wrapCursor moves cursor by delta through a list of length items,
+// wrapping around at either end.
+func wrapCursor(cursor int, delta int, length int) int {
+	if length == 0 {
+		return 0
+	}
+	return ((cursor+delta)%length + length) % length
+}
+
+func sideMenuItems() []MenuItem {
+	return []MenuItem{
+		{
+			title:  "White",
+			action: func() tea.Msg { return sideMsg(chess.White) },
+		},
+		{
+			title:  "Black",
+			action: func() tea.Msg { return sideMsg(chess.Black) },
+		},
+		{
+			title:  "Random",
+			action: func() tea.Msg { return sideMsg(chess.NoColor) },
+		},
+	}
+}
+
+func difficultyMenuItems() []MenuItem {
+	var items []MenuItem
+	for idx, level := range engine.Levels {
+		idx := idx
+		items = append(items, MenuItem{
+			title:  fmt.Sprintf("%s (~%d)", level.Name, level.Elo),
+			action: func() tea.Msg { return difficultyMsg(idx) },
+		})
+	}
+	return items
+}
+
 func (m *Model) gameNextStep() tea.Msg {
-	if m.game.Outcome() == chess.NoOutcome {
-		if m.game.Position().Turn() == chess.Black {
-			return GameMsg(GameCPUTurn)
-		}
+	if m.outcome() != chess.NoOutcome {
+		return GameMsg(GameOver)
+	}
+	if m.opponent == ComputerOpponent && m.game.Position().Turn() == m.cpuColor {
+		return GameMsg(GameCPUTurn)
 	}
 
 	return nil
 }
 
+func (m *Model) gameOptions() []func(*chess.Game) {
+	gameOptions := []func(*chess.Game){chess.UseNotation(m.notation.encoding())}
+
+	if m.gameFEN != "" {
+		if newOpts, err := chess.FEN(m.gameFEN); err == nil {
+			gameOptions = append(gameOptions, newOpts)
+		}
+	}
+	return gameOptions
+}
+
+func (m *Model) newGame(opp opponent) {
+	m.stopSearch()
+	m.cpu.NewGame()
+	m.opponent = opp
+	m.gameFEN = m.startFEN
+	m.game = *chess.NewGame(m.gameOptions()...)
+	m.startedAt = time.Now()
+	m.boardDirection = WhiteDirection
+	m.nextMoveField.Reset()
+	m.pastMovesView.SetContent("")
+	m.highlightsBoard = 0
+	m.selected = chess.NoSquare
+	m.guessList = []chess.Move{}
+	m.guessMenu = ""
+	m.guessCursor = NO_GUESS
+	m.err = nil
+	m.notice = ""
+	m.promotionMoves = nil
+	m.gameOverCursor = 0
+	m.updatePrompt()
+}
+
+// beginGame switches to the board with the clocks set for a new game.
+func (m *Model) beginGame() tea.Cmd {
+	m.mode = GameMode
+	return tea.Batch(m.startClock(), m.gameNextStep)
+}
+
+// replayGame rebuilds the game from its starting position by replaying
+// moves, picking up any change to the game options along the way.
+func (m *Model) replayGame(moves []*chess.Move) {
+	game := chess.NewGame(m.gameOptions()...)
+	for _, mov := range moves {
+		if err := game.Move(mov); err != nil {
+			break
+		}
+	}
+	m.game = *game
+	m.pastMovesView.SetContent(m.renderMoveList())
+}
+
+func (m *Model) setNotation(n notation) {
+	m.notation = n
+	m.replayGame(m.game.Moves())
+	m.refreshGuesses()
+}
+
+func (m *Model) refreshGuesses() {
+	input := m.nextMoveField.Value()
+
+	m.guessList = m.generateGuessList(input)
+	m.guessCursor = NO_GUESS
+	m.guessMenu = m.renderGuessList()
+
+	m.highlightsBoard = m.generateHighlights(input)
+}
+
+func (m *Model) updatePrompt() {
+	if m.opponent == HumanOpponent {
+		m.nextMoveField.Placeholder = fmt.Sprintf("%s to move", m.game.Position().Turn().Name())
+	} else {
+		m.nextMoveField.Placeholder = "Your move"
+	}
+}
+
+// afterHumanMove flips the board to face the side to move when playing
+// hot-seat with auto-flip on.
+func (m *Model) afterHumanMove() {
+	m.updatePrompt()
+	if m.opponent != HumanOpponent || !m.autoFlip {
+		return
+	}
+	if m.game.Position().Turn() == chess.White {
+		m.boardDirection = WhiteDirection
+	} else {
+		m.boardDirection = BlackDirection
+	}
+}
+
+func (m *Model) playMove(mov *chess.Move) tea.Cmd {
+	if m.checkFlag() {
+		return m.gameNextStep
+	}
+	if err := m.game.Move(mov); err != nil {
+		m.err = err
+		return nil
+	}
+	m.err = nil
+	m.selected = chess.NoSquare
+	m.claimDraws()
+	m.pressClock()
+	m.nextMoveField.Reset()
+	m.pastMovesView.SetContent(m.renderMoveList())
+	m.refreshGuesses()
+	m.afterHumanMove()
+	m.saveProgress()
+	return m.gameNextStep
+}
+
+// takeback rewinds the game by one ply, or by the computer's reply and the
+// move before it so that it is the human's turn again.
+func (m *Model) takeback() tea.Cmd {
+	if !m.takebacks {
+		m.err = errors.New("Takebacks are turned off for this game")
+		return nil
+	}
+
+	moves := m.game.Moves()
+	n := 1
+	if m.opponent == ComputerOpponent && !m.thinking {
+		n = 2
+	}
+	if len(moves) < n || (m.opponent == ComputerOpponent && len(moves) == n && m.cpuColor == chess.White) {
+		m.err = errors.New("There is no move of yours to take back")
+		return nil
+	}
+
+	m.stopSearch()
+	m.promotionMoves = nil
+	m.selected = chess.NoSquare
+	m.err = nil
+	positions := m.game.Positions()
+	m.replayGame(moves[:len(moves)-n])
+	if m.clock != nil {
+		now := time.Now()
+		m.clock.stop(now)
+		for _, color := range []chess.Color{chess.White, chess.Black} {
+			m.clock.takeBack(color, movesBy(positions, color, len(moves)), movesBy(positions, color, len(moves)-n))
+		}
+		m.clock.start(m.game.Position().Turn(), now)
+	}
+	m.nextMoveField.Reset()
+	m.refreshGuesses()
+	m.afterHumanMove()
+	m.saveProgress()
+	return m.gameNextStep
+}
+
+func (m *Model) cpuSearch() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
+	m.thinking = true
+	m.searchID++
+	id := m.searchID
+
+	game := m.game.Clone()
+	cpu := m.cpu
+	limits := engine.Limits{MoveTime: cpuMoveTime}
+	if m.clock != nil {
+		limits.MoveTime = m.clock.budget(m.cpuColor, time.Now())
+	}
+	return func() tea.Msg {
+		defer cancel()
+		result, err := cpu.Search(ctx, game, limits)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return errMsg(err)
+		}
+		return cpuMoveMsg{id: id, result: result}
+	}
+}
+
+func (m *Model) stopSearch() {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+		m.cancelSearch = nil
+	}
+	m.thinking = false
+	m.searchID++
+}
+
 func toPieceType(s string) chess.PieceType {
 	switch s {
 	case "K":
@@ -315,6 +728,8 @@ func toPiece(typ chess.PieceType, col chess.Color) chess.Piece {
 			return chess.WhiteKing
 		case chess.Queen:
 			return chess.WhiteQueen
+		case chess.Rook:
+			return chess.WhiteRook
 		case chess.Bishop:
 			return chess.WhiteBishop
 		case chess.Knight:
@@ -328,6 +743,8 @@ func toPiece(typ chess.PieceType, col chess.Color) chess.Piece {
 			return chess.BlackKing
 		case chess.Queen:
 			return chess.BlackQueen
+		case chess.Rook:
+			return chess.BlackRook
 		case chess.Bishop:
 			return chess.BlackBishop
 		case chess.Knight:
@@ -383,6 +800,10 @@ func substr(str string, first int, last int) string {
 }
 
 func (m *Model) generateGuessList(input string) []chess.Move {
+	if m.notation == SANNotation {
+		return m.sanGuessList(input)
+	}
+
 	var moveList []chess.Move = []chess.Move{}
 	if len(input) < 1 {
 		return moveList
@@ -393,7 +814,7 @@ func (m *Model) generateGuessList(input string) []chess.Move {
 		pieceType := toPieceType(oneRune)
 		if pieceType != chess.NoPieceType {
 			input = input[1:]
-			piece = toPiece(pieceType, chess.White)
+			piece = toPiece(pieceType, m.game.Position().Turn())
 		}
 	}
 
@@ -425,7 +846,7 @@ func (m *Model) generateGuessList(input string) []chess.Move {
 			continue
 		}
 
-		if piece == chess.Piece(chess.Pawn) && !haveSquare1 && !haveFile1 {
+		if piece.Type() == chess.Pawn && !haveSquare1 && !haveFile1 {
 			continue
 		}
 
@@ -515,22 +936,32 @@ func (m *Model) fullMoveHighlightUpdate(input string) bitboard {
 }
 
 func (m *Model) generateHighlights(input string) (newHighlights bitboard) {
+	if m.notation == SANNotation {
+		return m.sanHighlights(input)
+	}
+
 	newHighlights = 0
+	turn := m.game.Position().Turn()
+	pawn := toPiece(chess.Pawn, turn)
 	var piece chess.Piece
 	if len(input) >= 1 && pieceNameRegex.MatchString(input[0:1]) {
 		pieceType := toPieceType(input[0:1])
 		if pieceType == chess.NoPieceType {
 			return
 		}
-		piece = toPiece(pieceType, chess.White)
+		piece = toPiece(pieceType, turn)
 		input = input[1:]
 	} else {
-		piece = chess.WhitePawn
+		piece = pawn
 	}
 
+	// Captures and promotions only add to a move that is already complete.
+	input = strings.NewReplacer("x", "", "-", "").Replace(input)
+	input = substr(input, 0, 4)
+
 	switch len(input) {
 	case 0:
-		if piece != chess.WhitePawn {
+		if piece != pawn {
 			newHighlights = m.namedPieceHighlightUpdate(piece)
 		}
 	case 1:
@@ -563,102 +994,139 @@ func (m *Model) highlighted(sq chess.Square) bool {
 	return (bits.RotateLeft64(uint64(b), int(sq)+1) & 1) == 1
 }
 
+func (m *Model) flipBoard() {
+	if m.boardDirection == WhiteDirection {
+		m.boardDirection = BlackDirection
+	} else {
+		m.boardDirection = WhiteDirection
+	}
+}
+
 func (m *Model) RenderBoard() string {
+	moves := m.game.Moves()
+	return m.renderPosition(m.game.Position(), lastMove(moves, len(moves)))
+}
+
+// renderPosition draws pos, marking last, the move that led to it, when it
+// is not nil.
+func (m *Model) renderPosition(pos *chess.Position, last *chess.Move) string {
 	const numOfSquaresInRow = 8
 	var b *chess.Board
 
 	if m.boardDirection == WhiteDirection {
-		b = m.game.Position().Board()
+		b = pos.Board()
 	} else {
-		b = m.game.Position().Board().Flip(chess.UpDown).Flip(chess.LeftRight)
+		b = pos.Board().Flip(chess.UpDown).Flip(chess.LeftRight)
 	}
 
 	borderStyle := lipgloss.NewStyle().
-		Background(black).
-		Foreground(white)
+		Background(m.theme.border).
+		Foreground(m.theme.borderText)
 
-	var pieceString string
-	var pieceColorCode lipgloss.CompleteColor
+	var pieceColorCode lipgloss.TerminalColor
 	isWhite := true
 	squareBlack := lipgloss.NewStyle().
-		Background(cyan)
+		Background(m.theme.darkSquare)
 
 	squareWhite := lipgloss.NewStyle().
-		Background(magenta)
+		Background(m.theme.lightSquare)
 
 	squareBlackHighlight := lipgloss.NewStyle().
-		Background(brightgreen)
+		Background(m.theme.darkHighlight)
 
 	squareWhiteHighlight := lipgloss.NewStyle().
-		Background(green)
+		Background(m.theme.lightHighlight)
 
-	s := ""
+	marks := m.boardMarks(pos, last)
+	markStyles := m.markStyles()
 
-	if m.boardDirection == WhiteDirection {
-		s += borderStyle.Render("  A B C D E F G H   ")
-	} else {
-		s += borderStyle.Render("  H G F E D C B A   ")
+	// Each square is scale rows high and twice as many cells wide, with
+	// the piece and the rank and file names on its middle row.
+	scale := m.squareScale()
+	before := strings.Repeat(" ", scale-1)
+	after := strings.Repeat(" ", scale)
+	middle := scale / 2
+
+	files := "ABCDEFGH"
+	if m.boardDirection == BlackDirection {
+		files = "HGFEDCBA"
+	}
+	header := "  "
+	for _, f := range files {
+		header += before + string(f) + after
 	}
+	header = borderStyle.Render(header + "  ")
 
-	s += "\n"
+	s := header + "\n"
 	for r := 7; r >= 0; r-- {
-		var displayRank string
-		if m.boardDirection == WhiteDirection {
-			displayRank = borderStyle.Render(chess.Rank(r).String() + " ")
-		} else {
-			displayRank = borderStyle.Render(chess.Rank(7-r).String() + " ")
+		rank := chess.Rank(r).String()
+		if m.boardDirection == BlackDirection {
+			rank = chess.Rank(7 - r).String()
 		}
-		s += displayRank
 
-		for f := 0; f < numOfSquaresInRow; f++ {
+		for line := 0; line < scale; line++ {
+			displayRank := borderStyle.Render("  ")
+			if line == middle {
+				displayRank = borderStyle.Render(rank + " ")
+			}
+			s += displayRank
 
-			square := chess.NewSquare(chess.File(f), chess.Rank(r))
-			p := b.Piece(square)
+			for f := 0; f < numOfSquaresInRow; f++ {
 
-			if p == chess.NoPiece {
-				pieceString = "  "
-				pieceColorCode = black
-			} else {
-				pieceString = p.String() + " "
-				if p.Color() == chess.White {
-					pieceColorCode = white
-				} else {
-					pieceColorCode = black
+				square := chess.NewSquare(chess.File(f), chess.Rank(r))
+				p := b.Piece(square)
+				actual := square
+				if m.boardDirection == BlackDirection {
+					actual = chess.NewSquare(chess.File(7-f), chess.Rank(7-r))
 				}
-			}
-
-			var sqStyle lipgloss.Style
 
-			if isWhite {
-				if m.highlighted(square) {
-					sqStyle = squareWhiteHighlight
+				pieceString := before + " " + after
+				if p == chess.NoPiece {
+					pieceColorCode = m.theme.blackPiece
 				} else {
-					sqStyle = squareWhite
+					if line == middle {
+						pieceString = before + m.pieces.glyph(p) + after
+					}
+					if p.Color() == chess.White {
+						pieceColorCode = m.theme.whitePiece
+					} else {
+						pieceColorCode = m.theme.blackPiece
+					}
 				}
-			} else {
-				if m.highlighted(square) {
-					sqStyle = squareBlackHighlight
+
+				var sqStyle lipgloss.Style
+
+				// The cursor goes over the highlights, which go over the
+				// marks.
+				if m.cursorAt(actual) {
+					sqStyle = cursorStyle
+				} else if m.highlighted(square) {
+					if isWhite {
+						sqStyle = squareWhiteHighlight
+					} else {
+						sqStyle = squareBlackHighlight
+					}
+				} else if mark := marks[actual]; mark != NoMark {
+					sqStyle = markStyles[mark]
+				} else if isWhite {
+					sqStyle = squareWhite
 				} else {
 					sqStyle = squareBlack
 				}
+				sq := sqStyle.
+					Foreground(pieceColorCode).
+					Render(pieceString)
+
+				s += sq
+				isWhite = !isWhite
 			}
-			sq := sqStyle.
-				Foreground(pieceColorCode).
-				Render(pieceString)
 
-			s += sq
-			isWhite = !isWhite
+			s += displayRank
+			s += "\n"
 		}
-
 		isWhite = !isWhite
-		s += displayRank
-		s += "\n"
-	}
-	if m.boardDirection == WhiteDirection {
-		s += borderStyle.Render("  A B C D E F G H   ")
-	} else {
-		s += borderStyle.Render("  H G F E D C B A   ")
 	}
+	s += header
 	return s
 }
 
@@ -668,36 +1136,8 @@ func (m *Model) renderMoveList() string {
 	)
 }
 
-func toString(pt chess.PieceType) string {
-	switch pt {
-	case chess.King:
-		return "K"
-	case chess.Queen:
-		return "Q"
-	case chess.Rook:
-		return "R"
-	case chess.Bishop:
-		return "B"
-	case chess.Knight:
-		return "N"
-	}
-	return ""
-}
-
 func (m *Model) renderMove(mov chess.Move) string {
-	var board *chess.Board = m.game.Position().Board()
-	var str string = ""
-	s1 := mov.S1()
-	if pieceType := board.Piece(s1).Type(); pieceType != chess.Pawn {
-		str += toString(pieceType)
-	}
-	str += s1.String()
-	if mov.HasTag(chess.Capture) {
-		str += "x"
-	}
-	s2 := mov.S2()
-	str += s2.String()
-	return str
+	return m.notation.encoding().Encode(m.game.Position(), &mov)
 }
 
 func (m *Model) renderGuessList() string {
@@ -724,31 +1164,18 @@ func New(fen string) *Model {
 
 	pm := viewport.New(columnWidth, 5)
 
-	gameOptions := []func(*chess.Game){chess.UseNotation(chess.LongAlgebraicNotation{})}
-
-	if fen != "" {
-		if newOpts, err := chess.FEN(fen); err == nil {
-			gameOptions = append(gameOptions, newOpts)
-		}
-	}
-
-	return &Model{
-		mode: MainMenuMode,
-		menuItems: []MenuItem{
-			{
-				title:  "Vs. Computer",
-				action: func() tea.Msg { return GameMsg(GameStart) },
-			},
-			{
-				title:  "Vs. Player",
-				action: func() tea.Msg { return GameMsg(GameStart) },
-			},
-			{
-				title:  "Credits",
-				action: func() tea.Msg { return GameMsg(GameViewCredits) },
-			},
-		},
-		menuCursor: 0,
+	m := &Model{
+		mode:             MainMenuMode,
+		difficultyItems:  difficultyMenuItems(),
+		difficultyCursor: defaultDifficulty,
+		sideItems:        sideMenuItems(),
+		control:          timeControls[0],
+		timeControls:     timeControls,
+		timeControlItems: timeControlMenuItems(timeControls),
+		gameOverItems:    gameOverMenuItems(),
+		fenField:         newFENField(),
+		pathField:        newPathField(),
+		filterField:      newFilterField(),
 		credits: []creditVisual{
 			golang,
 			bubbletea,
@@ -756,14 +1183,75 @@ func New(fen string) *Model {
 		},
 		nextMoveField:   nmField,
 		pastMovesView:   pm,
-		game:            *chess.NewGame(gameOptions...),
+		startFEN:        fen,
+		gameFEN:         fen,
+		notation:        SANNotation,
+		opponent:        ComputerOpponent,
+		cpuColor:        chess.Black,
 		boardDirection:  WhiteDirection,
 		highlightsBoard: 0,
+		boardScale:      1,
+		cursor:          chess.E2,
+		selected:        chess.NoSquare,
 		guessList:       []chess.Move{},
 		guessMenu:       "",
 		guessCursor:     NO_GUESS,
 		err:             nil,
+		takebacks:       true,
+		themes:          builtinThemes,
+		cpu:             engine.NewSearcher(),
+	}
+	m.setTheme(builtinThemes[0])
+	m.applyConfig(config{})
+	m.game = *chess.NewGame(m.gameOptions()...)
+	m.refreshMainMenu()
+
+	return m
+}
+
+func mainMenuItems(resumable bool) []MenuItem {
+	var items []MenuItem
+	if resumable {
+		items = append(items, MenuItem{
+			title:  "Resume game",
+			action: func() tea.Msg { return GameMsg(GameResume) },
+		})
+	}
+	return append(items, []MenuItem{
+		{
+			title:  "Vs. Computer",
+			action: func() tea.Msg { return GameMsg(GameChooseDifficulty) },
+		},
+		{
+			title:  "Vs. Player",
+			action: func() tea.Msg { return GameMsg(GameStartVsPlayer) },
+		},
+		{
+			title:  "Load FEN",
+			action: func() tea.Msg { return GameMsg(GameLoadFEN) },
+		},
+		{
+			title:  "Open PGN",
+			action: func() tea.Msg { return GameMsg(GameOpenPGN) },
+		},
+		{
+			title:  "Settings",
+			action: func() tea.Msg { return GameMsg(GameSettings) },
+		},
+		{
+			title:  "Credits",
+			action: func() tea.Msg { return GameMsg(GameViewCredits) },
+		},
+	}...)
+}
+
+// refreshMainMenu offers "Resume game" only while there is a game to resume.
+func (m *Model) refreshMainMenu() {
+	items := mainMenuItems(hasAutosave())
+	if len(items) != len(m.menuItems) {
+		m.menuCursor = 0
 	}
+	m.menuItems = items
 }
 
 func (m *Model) Init() tea.Cmd {
@@ -771,6 +1259,15 @@ func (m *Model) Init() tea.Cmd {
 }
 
 func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.resize(msg.Width, msg.Height)
+		return m, nil
+	case fenSearchMsg, fenMatchMsg:
+		// A position search may finish while a game is being replayed.
+		return m.gameListUpdate(msg)
+	}
+
 	switch m.mode {
 	case MainMenuMode:
 		return m.mainMenuUpdate(msg)
@@ -778,6 +1275,26 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.gameUpdate(msg)
 	case CreditsMode:
 		return m.creditsUpdate(msg)
+	case DifficultyMode:
+		return m.difficultyUpdate(msg)
+	case SideMode:
+		return m.sideUpdate(msg)
+	case GameOverMode:
+		return m.gameOverUpdate(msg)
+	case AnalyzeMode:
+		return m.analyzeUpdate(msg)
+	case FENMode:
+		return m.fenUpdate(msg)
+	case OpenPGNMode:
+		return m.openPGNUpdate(msg)
+	case GameListMode:
+		return m.gameListUpdate(msg)
+	case ReplayMode:
+		return m.replayUpdate(msg)
+	case TimeControlMode:
+		return m.timeControlUpdate(msg)
+	case SettingsMode:
+		return m.settingsUpdate(msg)
 	}
 
 	return m, nil
@@ -790,7 +1307,7 @@ func (m *Model) mainMenuUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
 		case tea.KeyEnter:
-
+			m.err = nil
 			return m, m.menuItems[m.menuCursor].action
 		case tea.KeyDown:
 			if m.menuCursor < len(m.menuItems)-1 {
@@ -812,39 +1329,172 @@ func (m *Model) mainMenuUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.mode = GameMode
 		case GameViewCredits:
 			m.mode = CreditsMode
+		case GameChooseDifficulty:
+			m.opponent = ComputerOpponent
+			m.mode = DifficultyMode
+		case GameStartVsPlayer:
+			m.opponent = HumanOpponent
+			m.mode = TimeControlMode
+		case GameResume:
+			return m, m.resumeGame()
+		case GameSettings:
+			m.settingsCapture = false
+			m.mode = SettingsMode
+		case GameLoadFEN:
+			m.fenField.SetValue(m.startFEN)
+			m.fenField.Focus()
+			m.err = nil
+			m.mode = FENMode
+			return m, textinput.Blink
+		case GameOpenPGN:
+			m.pathField.SetValue(m.pgnPath)
+			m.pathField.Focus()
+			m.err = nil
+			m.mode = OpenPGNMode
+			return m, textinput.Blink
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Model) difficultyUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.mode = MainMenuMode
+		case tea.KeyEnter:
+			return m, m.difficultyItems[m.difficultyCursor].action
+		case tea.KeyDown:
+			m.difficultyCursor = wrapCursor(m.difficultyCursor, 1, len(m.difficultyItems))
+		case tea.KeyUp:
+			m.difficultyCursor = wrapCursor(m.difficultyCursor, -1, len(m.difficultyItems))
+		}
+		return m, nil
+	case difficultyMsg:
+		m.difficulty = int(msg)
+		m.mode = TimeControlMode
+	}
+
+	return m, nil
+}
+
+func (m *Model) sideUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.mode = TimeControlMode
+		case tea.KeyEnter:
+			return m, m.sideItems[m.sideCursor].action
+		case tea.KeyDown:
+			m.sideCursor = wrapCursor(m.sideCursor, 1, len(m.sideItems))
+		case tea.KeyUp:
+			m.sideCursor = wrapCursor(m.sideCursor, -1, len(m.sideItems))
 		}
+		return m, nil
+	case sideMsg:
+		human := chess.Color(msg)
+		if human == chess.NoColor {
+			human = []chess.Color{chess.White, chess.Black}[rand.Intn(2)]
+		}
+		return m, m.startVsComputer(human)
 	}
 
 	return m, nil
 }
 
+func (m *Model) startVsComputer(human chess.Color) tea.Cmd {
+	m.cpu.SetLevel(engine.Levels[m.difficulty])
+	m.newGame(ComputerOpponent)
+	m.cpuColor = human.Other()
+	if human == chess.Black {
+		m.boardDirection = BlackDirection
+	}
+	return m.beginGame()
+}
+
 func (m *Model) gameUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		tiCmd tea.Cmd
 		vpCmd tea.Cmd
 	)
 
+	if msg, ok := msg.(tea.KeyMsg); ok && m.promotionMoves != nil {
+		return m.promotionUpdate(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if cmd, handled := m.boardUpdate(msg); handled {
+			return m, cmd
+		}
+	}
+	if msg, ok := msg.(tea.MouseMsg); ok {
+		if cmd, handled := m.mouseUpdate(msg); handled {
+			return m, cmd
+		}
+	}
+
 	m.nextMoveField, tiCmd = m.nextMoveField.Update(msg)
 	m.pastMovesView, vpCmd = m.pastMovesView.Update(msg)
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		switch m.keys.lookup(msg) {
+		case FlipKey:
+			m.flipBoard()
+			return m, nil
+		case NotationKey:
+			m.setNotation(m.notation.next())
+			return m, nil
+		case ThemeKey:
+			m.nextTheme()
+			return m, nil
+		case UndoKey:
+			return m, m.takeback()
+		case CopyFENKey:
+			m.copyFEN()
+			return m, nil
+		case SavePGNKey:
+			m.saveGame()
+			return m, nil
+		case AutoFlipKey:
+			if m.opponent == HumanOpponent {
+				m.autoFlip = !m.autoFlip
+				m.afterHumanMove()
+			}
+			return m, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC:
 			return m, tea.Quit
 		case tea.KeyEsc:
 			return m, exitGame
+		case tea.KeyRunes, tea.KeyBackspace:
+			m.err = nil
+			m.notice = ""
+			m.selected = chess.NoSquare
 		case tea.KeyEnter:
+			if m.thinking {
+				return m, nil
+			}
 			input := m.nextMoveField.Value()
 
-			if err := m.game.MoveStr(input); err != nil {
-				// display err
-			} else {
-				m.nextMoveField.Reset()
-				m.pastMovesView.SetContent(m.renderMoveList())
+			mov, err := m.decodeMove(input)
+			if err != nil {
+				if choices := m.promotionChoices(input); choices != nil {
+					m.openPromotion(choices)
+				} else {
+					m.err = m.explainMove(input)
+				}
+				return m, nil
 			}
-
-			return m, m.gameNextStep
+			return m, m.playMove(mov)
 		case tea.KeyTab:
 			if guessLen := len(m.guessList); guessLen > 1 {
 				if m.guessCursor < guessLen-1 {
@@ -873,42 +1523,66 @@ func (m *Model) gameUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.nextMoveField.SetValue(selection)
 			m.highlightsBoard = m.generateHighlights(selection)
 			m.guessMenu = m.renderGuessList()
-		case tea.KeyCtrlF:
-			if m.boardDirection == WhiteDirection {
-				m.boardDirection = BlackDirection
-			} else {
-				m.boardDirection = WhiteDirection
-			}
-			return m, nil
 		case tea.KeyCtrlT:
 			m.guessMenu = "--------10--------20--------30--------40--------50--------60--------70"
 		}
 	default:
-		var input string = m.nextMoveField.Value()
-
-		m.guessList = m.generateGuessList(input)
-		m.guessCursor = NO_GUESS
-		m.guessMenu = m.renderGuessList()
-
-		m.highlightsBoard = m.generateHighlights(input)
+		m.refreshGuesses()
 
 		return m, nil
 
 	case GameMsg:
 		switch msg {
 		case GameExit:
+			m.stopSearch()
+			m.stopClock()
+			m.err = nil
 			m.mode = MainMenuMode
 		case GameCPUTurn:
-			moves := m.game.ValidMoves()
-			move := moves[rand.Intn(len(moves))]
-			m.game.Move(move)
-			m.pastMovesView.SetContent(m.renderMoveList())
-
-			return m, m.gameNextStep
+			if m.thinking {
+				return m, nil
+			}
+			return m, m.cpuSearch()
 		case GameOver:
-			return m, tea.Quit
+			m.stopSearch()
+			m.stopClock()
+			m.clearAutosave()
+			m.mode = GameOverMode
+		}
+	case cpuMoveMsg:
+		if msg.id != m.searchID {
+			// The search was called off, by a takeback for instance,
+			// even if it had already finished.
+			return m, nil
+		}
+		m.thinking = false
+		if m.checkFlag() {
+			return m, m.gameNextStep
 		}
+		if err := m.game.Move(msg.result.Move); err != nil {
+			return m, func() tea.Msg { return errMsg(err) }
+		}
+		// Whatever was typed or selected while it thought now goes with
+		// the new position.
+		m.selected = chess.NoSquare
+		m.claimDraws()
+		m.pressClock()
+		m.pastMovesView.SetContent(m.renderMoveList())
+		m.refreshGuesses()
+		m.saveProgress()
+
+		return m, m.gameNextStep
+	case clockTickMsg:
+		if msg.id != m.clockID {
+			return m, nil
+		}
+		if m.checkFlag() {
+			m.stopSearch()
+			return m, m.gameNextStep
+		}
+		return m, m.tickClock()
 	case errMsg:
+		m.thinking = false
 		m.err = msg
 		return m, nil
 	}
@@ -958,40 +1632,150 @@ func (m *Model) View() string {
 		return m.gameView()
 	case CreditsMode:
 		return m.creditsView()
+	case DifficultyMode:
+		return m.difficultyView()
+	case SideMode:
+		return m.sideView()
+	case GameOverMode:
+		return m.gameOverView()
+	case AnalyzeMode:
+		return m.analyzeView()
+	case FENMode:
+		return m.fenView()
+	case OpenPGNMode:
+		return m.openPGNView()
+	case GameListMode:
+		return m.gameListView()
+	case ReplayMode:
+		return m.replayView()
+	case TimeControlMode:
+		return m.timeControlView()
+	case SettingsMode:
+		return m.settingsView()
 	}
 
 	return ""
 }
 
 func (m *Model) mainMenuView() string {
+	side := sidebar
+	if m.startFEN != "" {
+		side += "\n\nStarting from\na loaded FEN"
+	}
+	if m.err != nil {
+		side = lipgloss.JoinVertical(lipgloss.Left, side, "", errorStyle.Render(m.err.Error()))
+	}
+
 	return lipgloss.JoinVertical(
 		lipgloss.Center,
 		renderTitle(),
 		lipgloss.JoinHorizontal(
 			lipgloss.Top,
 			m.renderMenuItems(),
-			sidebar,
+			side,
 		),
 	)
 }
 
+func setupView(items []MenuItem, cursor int, caption string) string {
+	return lipgloss.JoinVertical(
+		lipgloss.Center,
+		renderTitle(),
+		lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			renderMenu(items, cursor),
+			columnStyle.Render(caption+"\n\nesc back"),
+		),
+	)
+}
+
+func (m *Model) difficultyView() string {
+	level := engine.Levels[m.difficultyCursor]
+	var detail string
+	if level.Limits.Depth > 0 {
+		detail = fmt.Sprintf("Thinks %d ply ahead", level.Limits.Depth)
+	} else {
+		detail = "Full strength"
+	}
+
+	return setupView(m.difficultyItems, m.difficultyCursor, "Difficulty\n\n"+detail)
+}
+
+func (m *Model) sideView() string {
+	return setupView(m.sideItems, m.sideCursor, "Play as")
+}
+
+func (m *Model) helpText() string {
+	k := m.keys.label
+	help := "esc back\n^C quit\ntab toggle\n" + k(BoardKey) + " board\n" + k(FlipKey) + " flip\n" +
+		k(NotationKey) + " " + m.notation.String() + "\n" + k(ThemeKey) + " " + m.theme.name
+	if m.takebacks {
+		help += "\n" + k(UndoKey) + " undo"
+	}
+	help += "\n" + k(CopyFENKey) + " copy FEN\n" + k(SavePGNKey) + " save PGN"
+	if m.opponent == HumanOpponent {
+		if m.autoFlip {
+			help += "\n" + k(AutoFlipKey) + " autoflip on"
+		} else {
+			help += "\n" + k(AutoFlipKey) + " autoflip off"
+		}
+	}
+	return help
+}
+
 func (m *Model) gameView() string {
-	column1 := m.RenderBoard()
 	column2 := lipgloss.JoinVertical(
 		lipgloss.Left,
 		m.pastMovesView.View(),
 		m.nextMoveField.View(),
 	)
-	mainContent := lipgloss.JoinHorizontal(
-		lipgloss.Top,
-		columnStyle.Copy().Align(lipgloss.Center).Render(column1),
-		columnStyle.Copy().Align(lipgloss.Left).Render(column2),
-		columnStyle.Copy().MarginRight(0).Render("esc back\n^C quit\ntab toggle\n^F flip"),
-	)
+	if m.clock != nil {
+		top, bottom := m.renderClocks()
+		column2 = lipgloss.JoinVertical(lipgloss.Left, top, column2, bottom)
+	}
+	topCaptures, bottomCaptures := m.renderMaterial()
+	column2 = lipgloss.JoinVertical(lipgloss.Left, topCaptures, column2, bottomCaptures)
+	if m.promotionMoves != nil {
+		column2 = lipgloss.JoinVertical(
+			lipgloss.Left,
+			column2,
+			m.renderPromotionPicker(),
+		)
+	}
+	if m.notice != "" {
+		column2 = lipgloss.JoinVertical(
+			lipgloss.Left,
+			column2,
+			columnStyle.Copy().Margin(0).Render(m.notice),
+		)
+	}
+	if m.err != nil {
+		column2 = lipgloss.JoinVertical(
+			lipgloss.Left,
+			column2,
+			errorStyle.Render(m.err.Error()),
+		)
+	}
+	moves := columnStyle.Copy().Align(lipgloss.Left).Render(column2)
+	help := columnStyle.Copy().MarginRight(0).Render(m.helpText())
+	if !m.layout(moves, help) {
+		return m.tooSmallView(moves, help)
+	}
+	board := boardColumnStyle.Render(m.RenderBoard())
+	var mainContent string
+	if m.stacked {
+		mainContent = lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			lipgloss.JoinVertical(lipgloss.Left, board, moves),
+			help,
+		)
+	} else {
+		mainContent = lipgloss.JoinHorizontal(lipgloss.Top, board, moves, help)
+	}
 
 	footer := lipgloss.NewStyle().
 		Margin(margin).
-		Width(width - margin*2).
+		Width(lipgloss.Width(mainContent) - margin*2).
 		Height(2).
 		Render(m.guessMenu)
 
@@ -1012,8 +1796,8 @@ func (m *Model) creditsView() string {
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Printf("Alas, there's been an error: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -1023,9 +1807,19 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.bubble-chess.yaml)")
+	rootCmd.SilenceErrors = true
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $XDG_CONFIG_HOME/bubble-chess/config.toml)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	// rootCmd.Flags().StringVarP(&customStartFEN, "fen", "f", "", "FEN to start from")
+	rootCmd.Flags().StringVarP(&customStartFEN, "fen", "f", "", "FEN to start from")
+	rootCmd.Flags().StringVarP(&enginePath, "engine", "e", "", "path to a UCI engine to play against")
+	rootCmd.Flags().StringVarP(&notationName, "notation", "n", "san", "move notation: san, lan or uci")
+	rootCmd.Flags().StringVarP(&pieceSetName, "pieces", "p", "unicode", "piece set: unicode, figurine, ascii or nerd (needs a Nerd Font)")
+	rootCmd.Flags().StringVar(&themeName, "theme", "bubble", "board colors: bubble, wood, tournament, high-contrast, colorblind or one from themes.toml")
+	rootCmd.Flags().BoolVar(&noTakebacks, "no-takebacks", false, "do not allow moves to be taken back")
+	rootCmd.Flags().StringVar(&pgnDir, "pgn-dir", "", "directory saved games are written to (default is the working directory)")
+	rootCmd.Flags().StringVar(&annotator, "annotator", "", "name recorded in the Annotator tag of saved games")
+	rootCmd.Flags().BoolVar(&noMouse, "no-mouse", false, "leave the mouse to the terminal, for selecting text")
+	rootCmd.Flags().StringVarP(&timeControlSpec, "time-control", "t", "", "time control such as 5+3, 5+b3 (Bronstein), 5+d5 (delay) or 40/90+30,30+30")
 }
@@ -0,0 +1,98 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"testing"
+
+	"bubble-chess/engine"
+
+	"github.com/notnil/chess"
+)
+
+func TestStaleCPUMoveIgnored(t *testing.T) {
+	m := New("")
+	m.cpu.SetLevel(engine.Levels[0])
+	m.newGame(ComputerOpponent)
+	m.mode = GameMode
+	if err := m.game.MoveStr("e4"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The search finishes with a reply that is legal after either first
+	// move, then a takeback calls it off and a new move starts another
+	// before its result comes in.
+	m.cpuSearch()
+	reply := m.game.Position().ValidMoves()[0]
+	for _, mov := range m.game.Position().ValidMoves() {
+		if mov.String() == "e7e5" {
+			reply = mov
+		}
+	}
+	stale := cpuMoveMsg{id: m.searchID, result: engine.Result{Move: reply}}
+	m.stopSearch()
+	m.replayGame(nil)
+	if err := m.game.MoveStr("d4"); err != nil {
+		t.Fatal(err)
+	}
+	m.cpuSearch()
+
+	m.Update(stale)
+	if got := len(m.game.Moves()); got != 1 {
+		t.Errorf("stale reply %s was played, game has %d moves", stale.result.Move, got)
+	}
+	if !m.thinking {
+		t.Error("stale reply ended the new search")
+	}
+	if m.game.Position().Turn() != chess.Black {
+		t.Error("it should still be the computer's turn")
+	}
+}
+
+func TestCPUMoveRefreshesGuesses(t *testing.T) {
+	m := New("")
+	m.newGame(ComputerOpponent)
+	m.mode = GameMode
+	if err := m.game.MoveStr("e4"); err != nil {
+		t.Fatal(err)
+	}
+	m.cpuSearch()
+
+	// While the computer thinks, a piece is selected and a move begun.
+	m.selected = chess.G1
+	m.nextMoveField.SetValue("N")
+	m.refreshGuesses()
+	var reply *chess.Move
+	for _, mov := range m.game.Position().ValidMoves() {
+		if mov.String() == "e7e5" {
+			reply = mov
+		}
+	}
+	m.Update(cpuMoveMsg{id: m.searchID, result: engine.Result{Move: reply}})
+
+	if m.selected != chess.NoSquare {
+		t.Errorf("%s is still selected after the computer's move", m.selected)
+	}
+	if len(m.guessList) == 0 {
+		t.Fatal("no guesses for N after the computer's move")
+	}
+	for _, mov := range m.guessList {
+		if p := m.game.Position().Board().Piece(mov.S1()); p.Color() != chess.White {
+			t.Errorf("guess %s is not a move of white's", mov.String())
+		}
+	}
+}
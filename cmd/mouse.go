@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/notnil/chess"
+)
+
+// The board is drawn at the top left of the game view, inside the margin
+// of its column, below a row of file letters and between two columns of
+// rank numbers. Every square is squareScale rows high and twice as many
+// cells wide, whichever way the rest of the view is laid out.
+const (
+	boardLeft = margin + 2
+	boardTop  = 1
+)
+
+// squareAt returns the square drawn at column x and row y of the game
+// view, or chess.NoSquare when there is none.
+func (m *Model) squareAt(x int, y int) chess.Square {
+	if x < boardLeft || y < boardTop {
+		return chess.NoSquare
+	}
+	scale := m.squareScale()
+	f, r := (x-boardLeft)/(2*scale), 7-(y-boardTop)/scale
+	if f > 7 || r < 0 {
+		return chess.NoSquare
+	}
+	if m.boardDirection == BlackDirection {
+		f, r = 7-f, 7-r
+	}
+	return chess.NewSquare(chess.File(f), chess.Rank(r))
+}
+
+// mouseUpdate handles clicks on the board and reports whether it did. The
+// first click picks a piece and the second writes its move into the move
+// field, ready for enter. Clicking the same square once more plays it.
+func (m *Model) mouseUpdate(msg tea.MouseMsg) (tea.Cmd, bool) {
+	switch msg.Type {
+	case tea.MouseRight:
+		m.selectSquare(chess.NoSquare)
+		return nil, true
+	case tea.MouseLeft:
+	default:
+		return nil, false
+	}
+
+	sq := m.squareAt(msg.X, msg.Y)
+	if sq == chess.NoSquare || m.promotionMoves != nil {
+		return nil, true
+	}
+
+	if m.selected != chess.NoSquare && sq != m.selected {
+		var choices []chess.Move
+		for _, mov := range m.movesFrom(m.selected) {
+			if mov.S2() == sq {
+				choices = append(choices, mov)
+			}
+		}
+		if len(choices) == 1 && (sq != m.cursor || m.nextMoveField.Value() != m.renderMove(choices[0])) {
+			m.err = nil
+			m.cursor = sq
+			m.showSelection()
+			return nil, true
+		}
+	}
+
+	m.cursor = sq
+	return m.pickSquare(), true
+}
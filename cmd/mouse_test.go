@@ -0,0 +1,127 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+// scaledSizes are terminal sizes that give the game view each board scale.
+var scaledSizes = map[int][2]int{1: {64, 16}, 2: {80, 24}, 3: {120, 40}}
+
+func TestSquareAt(t *testing.T) {
+	tests := []struct {
+		direction direction
+		scale     int
+		x, y      int
+		want      chess.Square
+	}{
+		// Corners of the board.
+		{WhiteDirection, 1, 3, 1, chess.A8},
+		{WhiteDirection, 1, 18, 8, chess.H1},
+		{BlackDirection, 1, 3, 1, chess.H1},
+		{BlackDirection, 1, 18, 8, chess.A8},
+		{WhiteDirection, 2, 3, 1, chess.A8},
+		{WhiteDirection, 2, 34, 16, chess.H1},
+		{BlackDirection, 2, 3, 1, chess.H1},
+		{BlackDirection, 2, 34, 16, chess.A8},
+		{WhiteDirection, 3, 3, 1, chess.A8},
+		{WhiteDirection, 3, 50, 24, chess.H1},
+		{BlackDirection, 3, 3, 1, chess.H1},
+		{BlackDirection, 3, 50, 24, chess.A8},
+
+		// Either cell of a square, and each of its rows.
+		{WhiteDirection, 1, 11, 7, chess.E2},
+		{WhiteDirection, 1, 12, 7, chess.E2},
+		{BlackDirection, 1, 11, 7, chess.D7},
+		{WhiteDirection, 2, 19, 13, chess.E2},
+		{WhiteDirection, 2, 22, 14, chess.E2},
+		{BlackDirection, 2, 22, 14, chess.D7},
+		{WhiteDirection, 3, 27, 19, chess.E2},
+		{WhiteDirection, 3, 32, 21, chess.E2},
+		{BlackDirection, 3, 32, 21, chess.D7},
+
+		// The file letters and rank numbers around the board, and past it.
+		{WhiteDirection, 1, 3, 0, chess.NoSquare},
+		{WhiteDirection, 1, 2, 1, chess.NoSquare},
+		{WhiteDirection, 1, 19, 1, chess.NoSquare},
+		{WhiteDirection, 1, 3, 9, chess.NoSquare},
+		{BlackDirection, 1, 19, 8, chess.NoSquare},
+		{WhiteDirection, 2, 35, 16, chess.NoSquare},
+		{BlackDirection, 2, 34, 17, chess.NoSquare},
+		{WhiteDirection, 3, 51, 1, chess.NoSquare},
+		{BlackDirection, 3, 3, 25, chess.NoSquare},
+		{WhiteDirection, 3, 0, 0, chess.NoSquare},
+		{WhiteDirection, 3, 100, 30, chess.NoSquare},
+	}
+	for _, tt := range tests {
+		m := New("")
+		m.newGame(HumanOpponent)
+		m.mode = GameMode
+		m.boardDirection = tt.direction
+		size := scaledSizes[tt.scale]
+		m.resize(size[0], size[1])
+		m.gameView()
+		if m.boardScale != tt.scale {
+			t.Fatalf("%d×%d gives scale %d, want %d", size[0], size[1], m.boardScale, tt.scale)
+		}
+		if got := m.squareAt(tt.x, tt.y); got != tt.want {
+			t.Errorf("direction %d scale %d: squareAt(%d, %d) = %s, want %s", tt.direction, tt.scale, tt.x, tt.y, got, tt.want)
+		}
+	}
+}
+
+var ansiCodes = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// TestSquareAtMatchesView checks every piece drawn on the board against
+// the square a click on it picks.
+func TestSquareAtMatchesView(t *testing.T) {
+	for _, dir := range []direction{WhiteDirection, BlackDirection} {
+		for scale, size := range scaledSizes {
+			m := New("")
+			m.newGame(HumanOpponent)
+			m.mode = GameMode
+			m.pieces = ASCIIPieces
+			m.boardDirection = dir
+			m.resize(size[0], size[1])
+			lines := strings.Split(ansiCodes.ReplaceAllString(m.gameView(), ""), "\n")
+
+			seen := 0
+			for y := boardTop; y < boardTop+8*scale; y++ {
+				line := []rune(lines[y])
+				for x := boardLeft; x < boardLeft+16*scale && x < len(line); x++ {
+					if line[x] == ' ' {
+						continue
+					}
+					sq := m.squareAt(x, y)
+					p := m.game.Position().Board().Piece(sq)
+					if p == chess.NoPiece || m.pieces.glyph(p) != string(line[x]) {
+						t.Errorf("direction %d scale %d: %q is drawn at %d, %d, which picks %s", dir, scale, line[x], x, y, sq)
+					}
+					seen++
+				}
+			}
+			if seen != 32 {
+				t.Errorf("direction %d scale %d: found %d pieces on the board, want 32", dir, scale, seen)
+			}
+		}
+	}
+}
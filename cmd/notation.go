@@ -0,0 +1,94 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+type notation uint8
+
+const (
+	SANNotation = iota
+	LANNotation
+	UCINotation
+)
+
+var notationNames = []string{"SAN", "LAN", "UCI"}
+
+func (n notation) String() string {
+	return notationNames[n]
+}
+
+func (n notation) encoding() chess.Notation {
+	switch n {
+	case LANNotation:
+		return chess.LongAlgebraicNotation{}
+	case UCINotation:
+		return chess.UCINotation{}
+	}
+	return chess.AlgebraicNotation{}
+}
+
+func (n notation) next() notation {
+	return notation(wrapCursor(int(n), 1, len(notationNames)))
+}
+
+func parseNotation(name string) (notation, error) {
+	for idx, n := range notationNames {
+		if strings.EqualFold(name, n) {
+			return notation(idx), nil
+		}
+	}
+	return SANNotation, fmt.Errorf("unknown notation %q, expected one of %s", name, strings.Join(notationNames, ", "))
+}
+
+// sanGuessList returns the legal moves whose SAN starts with input. SAN
+// names the destination rather than the origin, so prefix matching is
+// all it takes.
+func (m *Model) sanGuessList(input string) []chess.Move {
+	var moveList []chess.Move = []chess.Move{}
+	if len(input) < 1 {
+		return moveList
+	}
+
+	pos := m.game.Position()
+	for _, mov := range m.game.ValidMoves() {
+		if strings.HasPrefix(chess.AlgebraicNotation{}.Encode(pos, mov), input) {
+			moveList = append(moveList, *mov)
+		}
+	}
+	return moveList
+}
+
+// sanHighlights marks the pieces that could make the move being typed and,
+// once a square has been named, where they would go.
+func (m *Model) sanHighlights(input string) bitboard {
+	withDestinations := squareNameRegex.MatchString(input)
+
+	var squares []chess.Square
+	for _, mov := range m.sanGuessList(input) {
+		squares = append(squares, mov.S1())
+		if withDestinations {
+			squares = append(squares, mov.S2())
+		}
+	}
+	return toBitboard(squares)
+}
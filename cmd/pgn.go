@@ -0,0 +1,186 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"bubble-chess/engine"
+
+	"github.com/notnil/chess"
+)
+
+// pgnLineLength is the longest line export format PGN allows.
+const pgnLineLength = 80
+
+type tagPair struct {
+	key   string
+	value string
+}
+
+var pgnEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func humanName() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "?"
+}
+
+func (m *Model) cpuName() string {
+	if named, ok := m.cpu.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("bubble-chess %s", engine.Levels[m.difficulty].Name)
+}
+
+// pgnTags returns the Seven Tag Roster for the game followed by whichever
+// optional tags apply.
+func (m *Model) pgnTags() []tagPair {
+	white, black := "?", "?"
+	if m.opponent == ComputerOpponent {
+		white, black = humanName(), m.cpuName()
+		if m.cpuColor == chess.White {
+			white, black = black, white
+		}
+	}
+
+	tags := []tagPair{
+		{"Event", "Casual game"},
+		{"Site", "bubble-chess"},
+		{"Date", m.startedAt.Format("2006.01.02")},
+		{"Round", "-"},
+		{"White", white},
+		{"Black", black},
+		{"Result", string(m.outcome())},
+	}
+	if m.gameFEN != "" {
+		tags = append(tags, tagPair{"SetUp", "1"}, tagPair{"FEN", m.gameFEN})
+	}
+	if m.timeControl != "" {
+		tags = append(tags, tagPair{"TimeControl", m.timeControl})
+	}
+	if m.flagged != chess.NoColor {
+		tags = append(tags, tagPair{"Termination", "time forfeit"})
+	}
+	if m.annotator != "" {
+		tags = append(tags, tagPair{"Annotator", m.annotator})
+	}
+	return tags
+}
+
+// moveNumber returns the full move number of pos, which the chess package
+// only exposes through its FEN.
+func moveNumber(pos *chess.Position) int {
+	fields := strings.Fields(pos.String())
+	n, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+// movetext returns the moves of the game in SAN, numbered and wrapped the
+// way PGN export format expects, followed by the result.
+func (m *Model) movetext() string {
+	var tokens []string
+	positions := m.game.Positions()
+	for i, mov := range m.game.Moves() {
+		pos := positions[i]
+		san := chess.AlgebraicNotation{}.Encode(pos, mov)
+		if pos.Turn() == chess.White {
+			san = fmt.Sprintf("%d. %s", moveNumber(pos), san)
+		} else if i == 0 {
+			san = fmt.Sprintf("%d... %s", moveNumber(pos), san)
+		}
+		tokens = append(tokens, san)
+	}
+	tokens = append(tokens, string(m.outcome()))
+
+	var lines []string
+	line := ""
+	for _, token := range tokens {
+		if line != "" && len(line)+1+len(token) > pgnLineLength {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += token
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+func (m *Model) pgn() string {
+	var s string
+	for _, tag := range m.pgnTags() {
+		s += fmt.Sprintf("[%s \"%s\"]\n", tag.key, pgnEscaper.Replace(tag.value))
+	}
+	return s + "\n" + m.movetext() + "\n"
+}
+
+// savePGN writes the game to a new file in the PGN directory and returns
+// its path.
+func (m *Model) savePGN() (string, error) {
+	dir := m.pgnDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format("20060102-150405")
+	for n := 1; ; n++ {
+		// Saves within the same second get -2, -3 and so on rather than
+		// overwriting each other.
+		name := fmt.Sprintf("bubble-chess-%s.pgn", stamp)
+		if n > 1 {
+			name = fmt.Sprintf("bubble-chess-%s-%d.pgn", stamp, n)
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if _, err := f.WriteString(m.pgn()); err != nil {
+			f.Close()
+			return "", err
+		}
+		return path, f.Close()
+	}
+}
+
+func (m *Model) saveGame() {
+	path, err := m.savePGN()
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.err = nil
+	m.notice = "Saved to " + path
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavePGNKeepsEarlierSaves(t *testing.T) {
+	m := New("")
+	m.newGame(HumanOpponent)
+	m.pgnDir = t.TempDir()
+
+	// Three saves in a row fall within the same second, or close to it.
+	paths := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		path, err := m.savePGN()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paths[path] {
+			t.Errorf("save %d went to %s again", i+1, path)
+		}
+		paths[path] = true
+		if err := m.game.MoveStr([]string{"e4", "e5", "Nf3"}[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := os.ReadDir(m.pgnDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("%s holds %d files, want 3", m.pgnDir, len(files))
+	}
+	for path := range paths {
+		if filepath.Dir(path) != m.pgnDir {
+			t.Errorf("saved to %s, outside %s", path, m.pgnDir)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+type pieceSet uint8
+
+const (
+	UnicodePieces = iota
+	FigurinePieces
+	ASCIIPieces
+	NerdFontPieces
+)
+
+var pieceSetNames = []string{"unicode", "figurine", "ascii", "nerd"}
+
+// Figurines use the solid symbols for both sides and leave telling them
+// apart to the piece colors.
+var figurineGlyphs = map[chess.PieceType]string{
+	chess.King:   "♚",
+	chess.Queen:  "♛",
+	chess.Rook:   "♜",
+	chess.Bishop: "♝",
+	chess.Knight: "♞",
+	chess.Pawn:   "♟",
+}
+
+// nerdFontGlyphs are the chess icons of the Material Design set that Nerd
+// Fonts patch in.
+var nerdFontGlyphs = map[chess.PieceType]string{
+	chess.King:   "\U000f0857",
+	chess.Queen:  "\U000f085a",
+	chess.Rook:   "\U000f085b",
+	chess.Bishop: "\U000f085c",
+	chess.Knight: "\U000f0858",
+	chess.Pawn:   "\U000f0859",
+}
+
+func (s pieceSet) String() string {
+	return pieceSetNames[s]
+}
+
+func parsePieceSet(name string) (pieceSet, error) {
+	for idx, n := range pieceSetNames {
+		if strings.EqualFold(name, n) {
+			return pieceSet(idx), nil
+		}
+	}
+	return UnicodePieces, fmt.Errorf("unknown piece set %q, expected one of %s", name, strings.Join(pieceSetNames, ", "))
+}
+
+// glyph returns how p is drawn in this set. White pieces are upper case in
+// ASCII and black ones lower case, as in a FEN.
+func (s pieceSet) glyph(p chess.Piece) string {
+	switch s {
+	case FigurinePieces:
+		return figurineGlyphs[p.Type()]
+	case ASCIIPieces:
+		letter := strings.ToUpper(p.Type().String())
+		if p.Color() == chess.Black {
+			letter = strings.ToLower(letter)
+		}
+		return letter
+	case NerdFontPieces:
+		return nerdFontGlyphs[p.Type()]
+	}
+	return p.String()
+}
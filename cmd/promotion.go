@@ -0,0 +1,114 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+)
+
+var promotionPieces = []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight}
+
+var promotionStyle = lipgloss.NewStyle().
+	Border(lipgloss.RoundedBorder()).
+	BorderForeground(magenta).
+	Padding(0, 1)
+
+// promotionChoices returns the promotions input could mean when it names a
+// pawn move to the last rank but leaves out the piece, queen first.
+func (m *Model) promotionChoices(input string) []chess.Move {
+	q, err := m.parseQuery(input)
+	if err != nil || q.promo != chess.NoPieceType {
+		return nil
+	}
+
+	legal := q.filter(m.game.Position())
+	if len(legal) == 0 {
+		return nil
+	}
+	for _, mov := range legal {
+		if mov.Promo() == chess.NoPieceType || mov.S1() != legal[0].S1() {
+			return nil
+		}
+	}
+
+	var choices []chess.Move
+	for _, pt := range promotionPieces {
+		for _, mov := range legal {
+			if mov.Promo() == pt {
+				choices = append(choices, *mov)
+			}
+		}
+	}
+	return choices
+}
+
+func (m *Model) openPromotion(choices []chess.Move) {
+	m.promotionMoves = choices
+	m.promotionCursor = 0
+	m.highlightsBoard = toBitboard([]chess.Square{choices[0].S1(), choices[0].S2()})
+}
+
+func (m *Model) closePromotion() {
+	m.promotionMoves = nil
+	m.promotionCursor = 0
+	m.refreshGuesses()
+}
+
+func (m *Model) promotionUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.closePromotion()
+	case tea.KeyUp, tea.KeyLeft, tea.KeyShiftTab:
+		m.promotionCursor = wrapCursor(m.promotionCursor, -1, len(m.promotionMoves))
+	case tea.KeyDown, tea.KeyRight, tea.KeyTab:
+		m.promotionCursor = wrapCursor(m.promotionCursor, 1, len(m.promotionMoves))
+	case tea.KeyEnter:
+		mov := m.promotionMoves[m.promotionCursor]
+		m.promotionMoves = nil
+		return m, m.playMove(&mov)
+	case tea.KeyRunes:
+		pt := toPieceType(strings.ToUpper(string(msg.Runes)))
+		for _, mov := range m.promotionMoves {
+			if mov.Promo() == pt {
+				m.promotionMoves = nil
+				return m, m.playMove(&mov)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) renderPromotionPicker() string {
+	turn := m.game.Position().Turn()
+	s := "Promote to"
+	for idx, mov := range m.promotionMoves {
+		pt := mov.Promo()
+		row := fmt.Sprintf(" %s %s %-6s ", strings.ToUpper(pt.String()), m.pieces.glyph(toPiece(pt, turn)), pieceName(pt))
+		if idx == m.promotionCursor {
+			row = selectedMenuItemStyle.Render(row)
+		}
+		s += "\n" + row
+	}
+	return promotionStyle.Render(s)
+}
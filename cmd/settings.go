@@ -0,0 +1,228 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"bubble-chess/engine"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The settings screen lists these followed by one row per key action.
+const (
+	NotationSetting = iota
+	ThemeSetting
+	PiecesSetting
+	SideSetting
+	DifficultySetting
+	TimeControlSetting
+	TakebacksSetting
+)
+
+var settingNames = []string{"Notation", "Theme", "Pieces", "Side", "Difficulty", "Time control", "Takebacks"}
+
+func (m *Model) settingRows() int {
+	return len(settingNames) + len(keyActionNames)
+}
+
+// Settings are shown and stepped from m.settings rather than the model, as
+// the menus and in-game keys change the model without saving.
+func (m *Model) settingIndex(row int) (int, int) {
+	switch row {
+	case NotationSetting:
+		n, _ := parseNotation(m.settings.Notation)
+		return int(n), len(notationNames)
+	case ThemeSetting:
+		for idx, t := range m.themes {
+			if strings.EqualFold(t.name, m.settings.Theme) {
+				return idx, len(m.themes)
+			}
+		}
+		return 0, len(m.themes)
+	case PiecesSetting:
+		p, _ := parsePieceSet(m.settings.Pieces)
+		return int(p), len(pieceSetNames)
+	case SideSetting:
+		for idx, name := range sideNames {
+			if strings.EqualFold(name, m.settings.Side) {
+				return idx, len(sideNames)
+			}
+		}
+		return 0, len(sideNames)
+	case DifficultySetting:
+		return m.settings.Difficulty - 1, len(engine.Levels)
+	case TimeControlSetting:
+		for idx, tc := range m.timeControls {
+			if tc.spec == m.settings.TimeControl {
+				return idx, len(m.timeControls)
+			}
+		}
+		return 0, len(m.timeControls)
+	case TakebacksSetting:
+		if m.settings.Takebacks != nil && !*m.settings.Takebacks {
+			return 1, 2
+		}
+		return 0, 2
+	}
+	return 0, 0
+}
+
+func (m *Model) settingValue(row int) string {
+	idx, _ := m.settingIndex(row)
+	switch row {
+	case NotationSetting:
+		return notation(idx).String()
+	case ThemeSetting:
+		return m.themes[idx].name
+	case PiecesSetting:
+		return pieceSet(idx).String()
+	case SideSetting:
+		return sideNames[idx]
+	case DifficultySetting:
+		return engine.Levels[idx].Name
+	case TimeControlSetting:
+		return m.timeControls[idx].name
+	case TakebacksSetting:
+		return []string{"on", "off"}[idx]
+	}
+	if m.settingsCapture && m.settingsCursor == row {
+		return "press a key"
+	}
+	return m.keys.label(keyAction(row - len(settingNames)))
+}
+
+// changeSetting steps the setting on row through its choices, puts it to
+// use straight away and saves it.
+func (m *Model) changeSetting(row int, delta int) {
+	idx, length := m.settingIndex(row)
+	if length == 0 {
+		return
+	}
+	idx = wrapCursor(idx, delta, length)
+	switch row {
+	case NotationSetting:
+		m.setNotation(notation(idx))
+		m.config.Notation = strings.ToLower(notation(idx).String())
+		m.settings.Notation = m.config.Notation
+	case ThemeSetting:
+		m.setTheme(m.themes[idx])
+		m.config.Theme = m.theme.name
+		m.settings.Theme = m.config.Theme
+	case PiecesSetting:
+		m.pieces = pieceSet(idx)
+		m.config.Pieces = m.pieces.String()
+		m.settings.Pieces = m.config.Pieces
+	case SideSetting:
+		m.sideCursor = idx
+		m.config.Side = sideNames[idx]
+		m.settings.Side = m.config.Side
+	case DifficultySetting:
+		m.difficultyCursor = idx
+		m.config.Difficulty = idx + 1
+		m.settings.Difficulty = m.config.Difficulty
+	case TimeControlSetting:
+		m.setTimeControl(m.timeControls[idx])
+		m.config.TimeControl = m.control.spec
+		m.settings.TimeControl = m.config.TimeControl
+	case TakebacksSetting:
+		m.takebacks = idx == 0
+		takebacks := m.takebacks
+		m.config.Takebacks = &takebacks
+		m.settings.Takebacks = &takebacks
+	}
+	m.err = m.saveConfig()
+}
+
+// bindSetting binds the key action on row to the key just pressed.
+func (m *Model) bindSetting(row int, msg tea.KeyMsg) {
+	m.settingsCapture = false
+	if msg.Type == tea.KeyEsc {
+		return
+	}
+	keys, err := m.keys.bind(keyAction(row-len(settingNames)), msg.String())
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.keys = keys
+	m.config.Keys = keyBindings(keys)
+	m.err = m.saveConfig()
+}
+
+func (m *Model) settingsUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	if m.settingsCapture {
+		m.bindSetting(m.settingsCursor, key)
+		return m, nil
+	}
+
+	switch key.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.err = nil
+		m.mode = MainMenuMode
+	case tea.KeyDown:
+		m.settingsCursor = wrapCursor(m.settingsCursor, 1, m.settingRows())
+	case tea.KeyUp:
+		m.settingsCursor = wrapCursor(m.settingsCursor, -1, m.settingRows())
+	case tea.KeyRight:
+		m.err = nil
+		m.changeSetting(m.settingsCursor, 1)
+	case tea.KeyLeft:
+		m.err = nil
+		m.changeSetting(m.settingsCursor, -1)
+	case tea.KeyEnter, tea.KeySpace:
+		m.err = nil
+		if m.settingsCursor >= len(settingNames) {
+			m.settingsCapture = true
+		} else {
+			m.changeSetting(m.settingsCursor, 1)
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) settingsView() string {
+	var items []MenuItem
+	for row := 0; row < m.settingRows(); row++ {
+		var title string
+		if row < len(settingNames) {
+			title = settingNames[row]
+		} else {
+			title = fmt.Sprintf("Key: %s", keyAction(row-len(settingNames)))
+		}
+		items = append(items, MenuItem{title: fmt.Sprintf("%-14s %s", title, m.settingValue(row))})
+	}
+
+	caption := "Settings\n\n←/→ change\nenter rebind key"
+	if m.configPath != "" {
+		caption += "\n\nSaved to " + filepath.Base(m.configPath)
+	}
+	if m.err != nil {
+		caption = lipgloss.JoinVertical(lipgloss.Left, caption, "", errorStyle.Render(m.err.Error()))
+	}
+	return setupView(items, m.settingsCursor, caption)
+}
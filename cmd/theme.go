@@ -0,0 +1,297 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme is the set of colors the board and menus are drawn in.
+type theme struct {
+	name           string
+	lightSquare    lipgloss.TerminalColor
+	darkSquare     lipgloss.TerminalColor
+	lightHighlight lipgloss.TerminalColor
+	darkHighlight  lipgloss.TerminalColor
+	cursor         lipgloss.TerminalColor
+	border         lipgloss.TerminalColor
+	borderText     lipgloss.TerminalColor
+	selection      lipgloss.TerminalColor
+	selectionText  lipgloss.TerminalColor
+	whitePiece     lipgloss.TerminalColor
+	blackPiece     lipgloss.TerminalColor
+	lastMove       lipgloss.TerminalColor
+	check          lipgloss.TerminalColor
+	destination    lipgloss.TerminalColor
+	capture        lipgloss.TerminalColor
+}
+
+var builtinThemes = []theme{
+	{
+		name:           "bubble",
+		lightSquare:    magenta,
+		darkSquare:     cyan,
+		lightHighlight: green,
+		darkHighlight:  brightgreen,
+		cursor:         yellow,
+		border:         black,
+		borderText:     white,
+		selection:      magenta,
+		selectionText:  white,
+		whitePiece:     white,
+		blackPiece:     black,
+		lastMove:       grey,
+		check:          red,
+		destination:    blue,
+		capture:        orange,
+	},
+	{
+		name:           "wood",
+		lightSquare:    lipgloss.Color("#C8A26B"),
+		darkSquare:     lipgloss.Color("#8B5A2B"),
+		lightHighlight: lipgloss.Color("#CDD26A"),
+		darkHighlight:  lipgloss.Color("#AAA23A"),
+		cursor:         lipgloss.Color("#E8C547"),
+		border:         lipgloss.Color("#4A2C12"),
+		borderText:     lipgloss.Color("#F0D9B5"),
+		selection:      lipgloss.Color("#8B5A2B"),
+		selectionText:  lipgloss.Color("#FFF8E7"),
+		whitePiece:     lipgloss.Color("#FFF8E7"),
+		blackPiece:     lipgloss.Color("#1A0F00"),
+		lastMove:       lipgloss.Color("#7D9E3C"),
+		check:          lipgloss.Color("#C0282D"),
+		destination:    lipgloss.Color("#5B7DB1"),
+		capture:        lipgloss.Color("#E69A28"),
+	},
+	{
+		name:           "tournament",
+		lightSquare:    lipgloss.Color("#A9B97A"),
+		darkSquare:     lipgloss.Color("#4E7837"),
+		lightHighlight: lipgloss.Color("#D6D65A"),
+		darkHighlight:  lipgloss.Color("#A8B23A"),
+		cursor:         lipgloss.Color("#F6F669"),
+		border:         lipgloss.Color("#2B3A22"),
+		borderText:     lipgloss.Color("#EEEED2"),
+		selection:      lipgloss.Color("#4E7837"),
+		selectionText:  lipgloss.Color("#FFFFFF"),
+		whitePiece:     lipgloss.Color("#FFFFFF"),
+		blackPiece:     lipgloss.Color("#000000"),
+		lastMove:       lipgloss.Color("#9FB8D0"),
+		check:          lipgloss.Color("#D42A2A"),
+		destination:    lipgloss.Color("#3C6EB4"),
+		capture:        lipgloss.Color("#E8871E"),
+	},
+	{
+		// Red and blue pieces stay readable on both pure white and pure
+		// black squares.
+		name:           "high-contrast",
+		lightSquare:    lipgloss.Color("#FFFFFF"),
+		darkSquare:     lipgloss.Color("#000000"),
+		lightHighlight: lipgloss.Color("#FFFF00"),
+		darkHighlight:  lipgloss.Color("#00FFFF"),
+		cursor:         lipgloss.Color("#00FF00"),
+		border:         lipgloss.Color("#000000"),
+		borderText:     lipgloss.Color("#FFFFFF"),
+		selection:      lipgloss.Color("#FFFF00"),
+		selectionText:  lipgloss.Color("#000000"),
+		whitePiece:     lipgloss.Color("#D70000"),
+		blackPiece:     lipgloss.Color("#005FFF"),
+		lastMove:       lipgloss.Color("#808080"),
+		check:          lipgloss.Color("#FF8700"),
+		destination:    lipgloss.Color("#AF87FF"),
+		capture:        lipgloss.Color("#FF00FF"),
+	},
+	{
+		// Blues and oranges from the Okabe-Ito palette, which stay apart
+		// for every common kind of color blindness.
+		name:           "colorblind",
+		lightSquare:    lipgloss.Color("#56B4E9"),
+		darkSquare:     lipgloss.Color("#0072B2"),
+		lightHighlight: lipgloss.Color("#E69F00"),
+		darkHighlight:  lipgloss.Color("#D55E00"),
+		cursor:         lipgloss.Color("#F0E442"),
+		border:         lipgloss.Color("#000000"),
+		borderText:     lipgloss.Color("#FFFFFF"),
+		selection:      lipgloss.Color("#0072B2"),
+		selectionText:  lipgloss.Color("#FFFFFF"),
+		whitePiece:     lipgloss.Color("#FFFFFF"),
+		blackPiece:     lipgloss.Color("#000000"),
+		lastMove:       lipgloss.Color("#999999"),
+		check:          lipgloss.Color("#EE3377"),
+		destination:    lipgloss.Color("#009E73"),
+		capture:        lipgloss.Color("#DDCC77"),
+	},
+}
+
+var colorRegex = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|25[0-5]|2[0-4][0-9]|1?[0-9]?[0-9])$`)
+
+// themeFile is the layout of themes.toml or themes.yaml in the config
+// directory.
+type themeFile struct {
+	Themes []themeSpec `toml:"themes" yaml:"themes"`
+}
+
+// themeSpec is a theme as written by the user. Colors are #rgb, #rrggbb or
+// an ANSI color number, and any left out are taken from the base theme.
+type themeSpec struct {
+	Name           string `toml:"name" yaml:"name"`
+	Base           string `toml:"base" yaml:"base"`
+	LightSquare    string `toml:"light-square" yaml:"light-square"`
+	DarkSquare     string `toml:"dark-square" yaml:"dark-square"`
+	LightHighlight string `toml:"light-highlight" yaml:"light-highlight"`
+	DarkHighlight  string `toml:"dark-highlight" yaml:"dark-highlight"`
+	Cursor         string `toml:"cursor" yaml:"cursor"`
+	Border         string `toml:"border" yaml:"border"`
+	BorderText     string `toml:"border-text" yaml:"border-text"`
+	Selection      string `toml:"selection" yaml:"selection"`
+	SelectionText  string `toml:"selection-text" yaml:"selection-text"`
+	WhitePiece     string `toml:"white-piece" yaml:"white-piece"`
+	BlackPiece     string `toml:"black-piece" yaml:"black-piece"`
+	LastMove       string `toml:"last-move" yaml:"last-move"`
+	Check          string `toml:"check" yaml:"check"`
+	Destination    string `toml:"destination" yaml:"destination"`
+	Capture        string `toml:"capture" yaml:"capture"`
+}
+
+func themeNames(themes []theme) []string {
+	names := make([]string, len(themes))
+	for idx, t := range themes {
+		names[idx] = t.name
+	}
+	return names
+}
+
+func findTheme(themes []theme, name string) (theme, error) {
+	for _, t := range themes {
+		if strings.EqualFold(name, t.name) {
+			return t, nil
+		}
+	}
+	return themes[0], fmt.Errorf("unknown theme %q, expected one of %s", name, strings.Join(themeNames(themes), ", "))
+}
+
+// build turns the spec into a theme on top of the named base, which may be
+// a built-in theme or one defined earlier in the file.
+func (s themeSpec) build(themes []theme) (theme, error) {
+	if s.Name == "" {
+		return theme{}, errors.New("every theme needs a name")
+	}
+	base := themes[0]
+	if s.Base != "" {
+		var err error
+		if base, err = findTheme(themes, s.Base); err != nil {
+			return theme{}, fmt.Errorf("theme %q: %w", s.Name, err)
+		}
+	}
+
+	t := base
+	t.name = s.Name
+	colors := []struct {
+		key   string
+		value string
+		field *lipgloss.TerminalColor
+	}{
+		{"light-square", s.LightSquare, &t.lightSquare},
+		{"dark-square", s.DarkSquare, &t.darkSquare},
+		{"light-highlight", s.LightHighlight, &t.lightHighlight},
+		{"dark-highlight", s.DarkHighlight, &t.darkHighlight},
+		{"cursor", s.Cursor, &t.cursor},
+		{"border", s.Border, &t.border},
+		{"border-text", s.BorderText, &t.borderText},
+		{"selection", s.Selection, &t.selection},
+		{"selection-text", s.SelectionText, &t.selectionText},
+		{"white-piece", s.WhitePiece, &t.whitePiece},
+		{"black-piece", s.BlackPiece, &t.blackPiece},
+		{"last-move", s.LastMove, &t.lastMove},
+		{"check", s.Check, &t.check},
+		{"destination", s.Destination, &t.destination},
+		{"capture", s.Capture, &t.capture},
+	}
+	for _, c := range colors {
+		if c.value == "" {
+			continue
+		}
+		if !colorRegex.MatchString(c.value) {
+			return theme{}, fmt.Errorf("theme %q: %s %q is not a color, expected #rrggbb or an ANSI color number", s.Name, c.key, c.value)
+		}
+		*c.field = lipgloss.Color(c.value)
+	}
+	return t, nil
+}
+
+// loadThemes returns the built-in themes followed by those in themes.toml,
+// themes.yaml or themes.yml in the config directory. A user theme with the
+// name of an earlier one replaces it.
+func loadThemes() ([]theme, error) {
+	themes := append([]theme{}, builtinThemes...)
+	path, err := findConfigFile("themes")
+	if err != nil || path == "" {
+		return themes, err
+	}
+	var file themeFile
+	if err := readConfigFile(path, &file); err != nil {
+		return themes, err
+	}
+
+	for _, spec := range file.Themes {
+		t, err := spec.build(themes)
+		if err != nil {
+			return themes, fmt.Errorf("%s: %w", path, err)
+		}
+		replaced := false
+		for idx := range themes {
+			if strings.EqualFold(themes[idx].name, t.name) {
+				themes[idx] = t
+				replaced = true
+			}
+		}
+		if !replaced {
+			themes = append(themes, t)
+		}
+	}
+	return themes, nil
+}
+
+// setTheme draws the board in t from now on and restyles the menus and
+// clocks to match.
+func (m *Model) setTheme(t theme) {
+	m.theme = t
+	selectedMenuItemStyle = selectedMenuItemStyle.Copy().
+		Background(t.selection).
+		Foreground(t.selectionText)
+	runningClockStyle = selectedMenuItemStyle.Copy().
+		Width(columnWidth)
+	cursorStyle = cursorStyle.Copy().
+		Background(t.cursor)
+	promotionStyle = promotionStyle.Copy().
+		BorderForeground(t.selection)
+}
+
+func (m *Model) nextTheme() {
+	for idx, t := range m.themes {
+		if t.name == m.theme.name {
+			m.setTheme(m.themes[(idx+1)%len(m.themes)])
+			return
+		}
+	}
+	m.setTheme(m.themes[0])
+}
@@ -0,0 +1,271 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bubble-chess/engine"
+
+	"github.com/notnil/chess"
+	"github.com/spf13/cobra"
+)
+
+var uciCmd = &cobra.Command{
+	Use:   "uci",
+	Short: "Run the built-in engine as a UCI engine",
+	Long: `Speaks the Universal Chess Interface on stdin and stdout
+so the built-in engine can be used from chess GUIs and
+tournament managers.`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		newUCIServer(os.Stdout).serve(os.Stdin)
+	},
+}
+
+type uciServer struct {
+	out      io.Writer
+	outMu    sync.Mutex
+	searcher *engine.Searcher
+	game     *chess.Game
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newUCIServer(out io.Writer) *uciServer {
+	s := &uciServer{
+		out:      out,
+		searcher: engine.NewSearcher(),
+		game:     chess.NewGame(),
+	}
+	s.searcher.Info = func(r engine.Result, elapsed time.Duration) {
+		s.println(engine.InfoLine(r, elapsed))
+	}
+	return s
+}
+
+func (s *uciServer) println(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	fmt.Fprintln(s.out, line)
+}
+
+func (s *uciServer) serve(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			s.println("id name " + sidebar)
+			s.println("id author Daniel Gerard Ramirez")
+			s.println(fmt.Sprintf("option name Skill Level type spin default %d min 1 max %d", len(engine.Levels), len(engine.Levels)))
+			s.println("uciok")
+		case "isready":
+			s.println("readyok")
+		case "ucinewgame":
+			s.stop()
+			s.searcher.NewGame()
+			s.game = chess.NewGame()
+		case "setoption":
+			s.setOption(fields[1:])
+		case "position":
+			s.stop()
+			if err := s.position(fields[1:]); err != nil {
+				// Searching the previous position instead would answer
+				// with a move for the wrong one.
+				s.game = nil
+				s.println("info string " + err.Error())
+			}
+		case "go":
+			s.stop()
+			s.goSearch(fields[1:])
+		case "stop":
+			s.stop()
+		case "quit":
+			s.stop()
+			return
+		}
+	}
+	s.stop()
+}
+
+func (s *uciServer) setOption(fields []string) {
+	line := strings.Join(fields, " ")
+	name, value, ok := strings.Cut(strings.TrimPrefix(line, "name "), " value ")
+	if !ok {
+		return
+	}
+	switch strings.ToLower(name) {
+	case "skill level":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > len(engine.Levels) {
+			return
+		}
+		s.searcher.SetLevel(engine.Levels[n-1])
+	}
+}
+
+func (s *uciServer) position(fields []string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("position needs startpos or fen")
+	}
+
+	var opts []func(*chess.Game)
+	var rest []string
+	switch fields[0] {
+	case "startpos":
+		rest = fields[1:]
+	case "fen":
+		end := len(fields)
+		for i, f := range fields {
+			if f == "moves" {
+				end = i
+				break
+			}
+		}
+		fen, err := chess.FEN(strings.Join(fields[1:end], " "))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, fen)
+		rest = fields[end:]
+	default:
+		return fmt.Errorf("unknown position type %s", fields[0])
+	}
+
+	opts = append(opts, chess.UseNotation(chess.UCINotation{}))
+	game := chess.NewGame(opts...)
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, mov := range rest[1:] {
+			if err := game.MoveStr(mov); err != nil {
+				return err
+			}
+		}
+	}
+	s.game = game
+	return nil
+}
+
+func (s *uciServer) goSearch(fields []string) {
+	if s.game == nil {
+		s.println("info string no valid position to search")
+		s.println("bestmove 0000")
+		return
+	}
+
+	var limits engine.Limits
+	var remaining, increment time.Duration
+	var movesToGo int
+	turn := s.game.Position().Turn()
+
+	for i := 0; i+1 < len(fields); i++ {
+		n, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil {
+			continue
+		}
+		ms := time.Duration(n) * time.Millisecond
+		switch fields[i] {
+		case "depth":
+			limits.Depth = int(n)
+		case "nodes":
+			limits.Nodes = n
+		case "movetime":
+			limits.MoveTime = ms
+		case "movestogo":
+			movesToGo = int(n)
+		case "wtime":
+			if turn == chess.White {
+				remaining = ms
+			}
+		case "btime":
+			if turn == chess.Black {
+				remaining = ms
+			}
+		case "winc":
+			if turn == chess.White {
+				increment = ms
+			}
+		case "binc":
+			if turn == chess.Black {
+				increment = ms
+			}
+		}
+		i++
+	}
+	if remaining > 0 && limits.MoveTime == 0 {
+		limits.MoveTime = engine.TimeBudget(remaining, increment, movesToGo)
+	}
+	infinite := false
+	for _, f := range fields {
+		if f == "infinite" {
+			infinite = true
+			limits = engine.Limits{Depth: engine.MaxPly - 1}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.done = done
+	game := s.game.Clone()
+
+	go func() {
+		defer close(done)
+		result, err := s.searcher.Search(ctx, game, limits)
+		if infinite {
+			// The search may end early, on a mate or at the skill
+			// level's depth, but bestmove has to wait for stop.
+			<-ctx.Done()
+		}
+		if err != nil {
+			s.println("bestmove 0000")
+			return
+		}
+		line := "bestmove " + result.Move.String()
+		if len(result.PV) > 1 && result.PV[0] == result.Move {
+			line += " ponder " + result.PV[1].String()
+		}
+		s.println(line)
+	}()
+}
+
+// stop cancels any running search and waits for its bestmove to be sent.
+func (s *uciServer) stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+	s.cancel = nil
+	s.done = nil
+}
+
+func init() {
+	rootCmd.AddCommand(uciCmd)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startUCIServer runs a server on a pipe and returns where to write
+// commands and a channel of the lines it answers with.
+func startUCIServer(t *testing.T) (io.WriteCloser, <-chan string) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	lines := make(chan string, 256)
+	go func() {
+		newUCIServer(outW).serve(inR)
+		outW.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return inW, lines
+}
+
+func send(t *testing.T, w io.Writer, commands ...string) {
+	t.Helper()
+	for _, c := range commands {
+		if _, err := io.WriteString(w, c+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// waitBestMove returns the first bestmove line within timeout, or "".
+func waitBestMove(lines <-chan string, timeout time.Duration) string {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return ""
+			}
+			if strings.HasPrefix(line, "bestmove") {
+				return line
+			}
+		case <-deadline:
+			return ""
+		}
+	}
+}
+
+func TestUCIInfiniteWaitsForStop(t *testing.T) {
+	in, lines := startUCIServer(t)
+	// The search finds the mate at once and ends well before stop.
+	send(t, in,
+		"position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+		"go infinite",
+	)
+	if line := waitBestMove(lines, 300*time.Millisecond); line != "" {
+		t.Fatalf("go infinite answered %q before stop", line)
+	}
+	send(t, in, "stop")
+	if line := waitBestMove(lines, 5*time.Second); !strings.HasPrefix(line, "bestmove a1a8") {
+		t.Errorf("stop answered %q, want bestmove a1a8", line)
+	}
+}
+
+func TestUCIIllegalPosition(t *testing.T) {
+	in, lines := startUCIServer(t)
+	send(t, in,
+		"position startpos moves e2e4",
+		"position startpos moves e2e5",
+		"go depth 1",
+	)
+	if line := waitBestMove(lines, 5*time.Second); line != "bestmove 0000" {
+		t.Errorf("go after an illegal position answered %q, want bestmove 0000", line)
+	}
+	send(t, in, "position startpos moves e2e4", "go depth 1")
+	if line := waitBestMove(lines, 5*time.Second); line == "" || line == "bestmove 0000" {
+		t.Errorf("go after a legal position answered %q", line)
+	}
+}
@@ -0,0 +1,364 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/notnil/chess"
+	"github.com/spf13/cobra"
+)
+
+const (
+	listRows     = 12
+	moveListRows = 12
+)
+
+var viewCmd = &cobra.Command{
+	Use:   "view <file.pgn>",
+	Short: "Replay the games in a PGN file",
+	Long: `Lists the games in a PGN file and steps through
+the moves of whichever one is picked.`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, cfgPath, err := loadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+		configFlags(cmd, cfg)
+		cmd.SilenceUsage = true
+
+		m := New("")
+		if err := m.setDisplay(notationName, pieceSetName, themeName); err != nil {
+			return err
+		}
+		if err := m.applyConfig(cfg); err != nil {
+			return err
+		}
+		m.configPath = cfgPath
+		if err := m.openPGN(args[0]); err != nil {
+			return err
+		}
+		defer m.cpu.Close()
+
+		p := tea.NewProgram(m)
+
+		_, err = p.Run()
+		return err
+	},
+}
+
+var replayMoveStyle = lipgloss.NewStyle().
+	Width(2*columnWidth + margin*2)
+
+// scanPGN calls fn with the text of every game in r and the offset it
+// starts at, one game at a time. The chess package's own scanner expects
+// movetext to start at move 1, which games set up from a FEN do not.
+func scanPGN(r io.Reader, fn func(offset int64, text string) error) error {
+	br := bufio.NewReader(r)
+	var game strings.Builder
+	var start, offset int64
+	inMoves := false
+	for {
+		line, err := br.ReadString('\n')
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && inMoves {
+			if err := fn(start, game.String()); err != nil {
+				return err
+			}
+			game.Reset()
+			start = offset
+			inMoves = false
+		}
+		if trimmed != "" && !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "%") {
+			inMoves = true
+		}
+		game.WriteString(line)
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(game.String()) != "" {
+		return fn(start, game.String())
+	}
+	return nil
+}
+
+var pgnCommentRegex = regexp.MustCompile(`\{[^}]*\}`)
+
+// decodePGN decodes a single game. The chess package indexes past the
+// start of the move list when a comment comes before the first move, so
+// such games are read again without their comments.
+func decodePGN(text string) (*chess.Game, error) {
+	game, err := tryDecodePGN(text)
+	if errors.Is(err, errPGNPanic) {
+		return tryDecodePGN(pgnCommentRegex.ReplaceAllString(text, ""))
+	}
+	return game, err
+}
+
+var errPGNPanic = errors.New("chess: pgn decode failed")
+
+func tryDecodePGN(text string) (game *chess.Game, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			game, err = nil, fmt.Errorf("%w: %v", errPGNPanic, r)
+		}
+	}()
+
+	opt, err := chess.PGN(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return chess.NewGame(opt), nil
+}
+
+func readTags(text string) map[string]string {
+	tags := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		key, value, ok := strings.Cut(line[1:len(line)-1], " ")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		value = strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`)
+		tags[key] = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
+	}
+	return tags
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+func (m *Model) openPGN(path string) error {
+	path = expandHome(path)
+	entries, err := indexPGN(path)
+	if err != nil {
+		return err
+	}
+	m.pgnPath = path
+	m.pgnGames = entries
+	m.pgnCursor = 0
+	m.pgnSort = 0
+	m.pgnSortDesc = false
+	m.fenMatches = nil
+	m.filterField.Reset()
+	m.filterField.Focus()
+	m.applyFilter()
+	m.mode = GameListMode
+	return nil
+}
+
+func newPathField() textinput.Model {
+	field := textinput.New()
+	field.Placeholder = "path/to/games.pgn"
+	field.Width = fenFieldWidth
+	return field
+}
+
+func (m *Model) openPGNUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.err = nil
+			m.mode = MainMenuMode
+			return m, nil
+		case tea.KeyEnter:
+			if err := m.openPGN(strings.TrimSpace(m.pathField.Value())); err != nil {
+				m.err = err
+			} else {
+				m.err = nil
+			}
+			return m, nil
+		}
+	}
+
+	m.pathField, cmd = m.pathField.Update(msg)
+	if _, ok := msg.(tea.KeyMsg); ok {
+		m.err = nil
+	}
+	return m, cmd
+}
+
+func (m *Model) openPGNView() string {
+	form := lipgloss.JoinVertical(
+		lipgloss.Left,
+		"Open PGN",
+		"",
+		m.pathField.View(),
+	)
+	if m.err != nil {
+		form = lipgloss.JoinVertical(
+			lipgloss.Left,
+			form,
+			errorStyle.Copy().Width(fenFieldWidth).Render(m.err.Error()),
+		)
+	}
+	form = lipgloss.JoinVertical(lipgloss.Left, form, "", "enter open\nesc back")
+
+	return lipgloss.JoinVertical(
+		lipgloss.Center,
+		renderTitle(),
+		form,
+	)
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// listWindow returns the first row to show so that cursor stays in view.
+func listWindow(cursor, rows, length int) int {
+	first := cursor - rows/2
+	if first > length-rows {
+		first = length - rows
+	}
+	return max(first, 0)
+}
+
+// stepReplay shows the position after ply half-moves of the game being
+// replayed. The board marks the move that led to it.
+func (m *Model) stepReplay(ply int) {
+	if ply < 0 || ply > len(m.replaying.Moves()) {
+		return
+	}
+	m.replayPly = ply
+	m.highlightsBoard = 0
+}
+
+func (m *Model) replayUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyEsc:
+			m.highlightsBoard = 0
+			m.mode = GameListMode
+		case tea.KeyLeft, tea.KeyUp:
+			m.stepReplay(m.replayPly - 1)
+		case tea.KeyRight, tea.KeyDown:
+			m.stepReplay(m.replayPly + 1)
+		case tea.KeyHome:
+			m.stepReplay(0)
+		case tea.KeyEnd:
+			m.stepReplay(len(m.replaying.Moves()))
+		}
+		if m.keys.lookup(msg) == FlipKey {
+			m.flipBoard()
+		}
+	}
+
+	return m, nil
+}
+
+// renderReplayMoves lists the moves one full move to a line, with the
+// move that led to the shown position highlighted.
+func (m *Model) renderReplayMoves() string {
+	positions := m.replaying.Positions()
+	var lines []string
+	current := 0
+	for i, mov := range m.replaying.Moves() {
+		pos := positions[i]
+		text := m.notation.encoding().Encode(pos, mov)
+		if i+1 == m.replayPly {
+			text = selectedMenuItemStyle.Render(text)
+		}
+		switch {
+		case pos.Turn() == chess.White:
+			lines = append(lines, fmt.Sprintf("%d. %s", moveNumber(pos), text))
+		case i == 0:
+			lines = append(lines, fmt.Sprintf("%d... %s", moveNumber(pos), text))
+		default:
+			lines[len(lines)-1] += " " + text
+		}
+		if i+1 == m.replayPly {
+			current = len(lines) - 1
+		}
+	}
+	lines = append(lines, string(m.replaying.Outcome()))
+
+	first := listWindow(current, moveListRows, len(lines))
+	last := min(first+moveListRows, len(lines))
+	return strings.Join(lines[first:last], "\n")
+}
+
+func (m *Model) replayView() string {
+	entry := m.pgnGames[m.pgnView[m.pgnCursor]]
+	headers := fmt.Sprintf("%s\n%s\n%s, %s\n%s",
+		entry.tag("White"), entry.tag("Black"), entry.tag("Event"), entry.tag("Date"), entry.tag("Result"))
+
+	return lipgloss.JoinHorizontal(
+		lipgloss.Top,
+		columnStyle.Copy().Align(lipgloss.Center).Render(m.renderPosition(m.replaying.Positions()[m.replayPly], lastMove(m.replaying.Moves(), m.replayPly))),
+		replayMoveStyle.Render(lipgloss.JoinVertical(
+			lipgloss.Left,
+			headers,
+			"",
+			m.renderReplayMoves(),
+			"",
+			"←/→ step  home/end jump  "+m.keys.label(FlipKey)+" flip  esc back",
+		)),
+	)
+}
+
+func init() {
+	rootCmd.AddCommand(viewCmd)
+
+	viewCmd.Flags().StringVarP(&notationName, "notation", "n", "san", "move notation: san, lan or uci")
+	viewCmd.Flags().StringVarP(&pieceSetName, "pieces", "p", "unicode", "piece set: unicode, figurine, ascii or nerd (needs a Nerd Font)")
+	viewCmd.Flags().StringVar(&themeName, "theme", "bubble", "board colors: bubble, wood, tournament, high-contrast, colorblind or one from themes.toml")
+}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// MateScore is the score of a position where the side to move has been
+// checkmated. Scores within MaxPly of it encode a forced mate.
+const (
+	MateScore = 100000
+	MaxPly    = 64
+)
+
+const DefaultMoveTime = time.Second
+
+var ErrNoMoves = errors.New("engine: no legal moves in position")
+
+// Engine picks a move for the side to move in a game.
+type Engine interface {
+	Search(ctx context.Context, game *chess.Game, limits Limits) (Result, error)
+	SetLevel(level Level)
+	NewGame()
+	Close() error
+}
+
+// Limits bounds a single search. Zero values mean no limit, and a search
+// with no limits at all falls back to DefaultMoveTime.
+type Limits struct {
+	Depth    int
+	Nodes    int64
+	MoveTime time.Duration
+}
+
+// Result is the outcome of a search. Score is in centipawns from the
+// point of view of the side to move.
+type Result struct {
+	Move  *chess.Move
+	Score int
+	Depth int
+	Nodes int64
+	PV    []*chess.Move
+}
+
+// TimeBudget returns how long to think about a move given the time left on
+// the clock, the increment gained after the move and the number of moves
+// until the next time control, or zero when that is unknown.
+func TimeBudget(remaining, increment time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	budget := remaining/time.Duration(movesToGo) + increment*3/4
+	if max := remaining / 2; budget > max {
+		budget = max
+	}
+	if budget < 10*time.Millisecond {
+		budget = 10 * time.Millisecond
+	}
+	return budget
+}
+
+// IsMate reports whether score encodes a forced mate, and in how many
+// moves. The move count is negative when the side to move is being mated.
+func IsMate(score int) (bool, int) {
+	if score > MateScore-MaxPly {
+		return true, (MateScore - score + 1) / 2
+	}
+	if score < -MateScore+MaxPly {
+		return true, -(MateScore + score) / 2
+	}
+	return false, 0
+}
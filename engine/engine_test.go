@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		increment time.Duration
+		movesToGo int
+		want      time.Duration
+	}{
+		{"sudden death", time.Minute, 0, 0, 2 * time.Second},
+		{"increment", time.Minute, 2 * time.Second, 0, 3500 * time.Millisecond},
+		{"moves to go", 10 * time.Second, 0, 10, time.Second},
+		{"at most half the clock", time.Second, 10 * time.Second, 30, 500 * time.Millisecond},
+		{"at least 10ms", 100 * time.Millisecond, 0, 30, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TimeBudget(tt.remaining, tt.increment, tt.movesToGo); got != tt.want {
+				t.Errorf("TimeBudget(%v, %v, %d) = %v, want %v", tt.remaining, tt.increment, tt.movesToGo, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsMate(t *testing.T) {
+	tests := []struct {
+		score int
+		mate  bool
+		moves int
+	}{
+		{0, false, 0},
+		{900, false, 0},
+		{-900, false, 0},
+		{MateScore - MaxPly, false, 0},
+		{-MateScore + MaxPly, false, 0},
+		{MateScore - 1, true, 1},
+		{MateScore - 3, true, 2},
+		{MateScore - 4, true, 2},
+		{-MateScore, true, 0},
+		{-MateScore + 2, true, -1},
+		{-MateScore + 4, true, -2},
+	}
+	for _, tt := range tests {
+		mate, moves := IsMate(tt.score)
+		if mate != tt.mate || moves != tt.moves {
+			t.Errorf("IsMate(%d) = %v, %d, want %v, %d", tt.score, mate, moves, tt.mate, tt.moves)
+		}
+	}
+}
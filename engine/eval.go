@@ -0,0 +1,186 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/notnil/chess"
+)
+
+// pieceBoards holds one bitboard per chess.Piece, indexed by chess.Piece
+// and with bit n set when chess.Square(n) is occupied.
+type pieceBoards [13]uint64
+
+var pieceValues = [...]int{
+	chess.NoPieceType: 0,
+	chess.King:        0,
+	chess.Queen:       900,
+	chess.Rook:        500,
+	chess.Bishop:      330,
+	chess.Knight:      320,
+	chess.Pawn:        100,
+}
+
+// Piece-square tables are written from white's point of view with the
+// eighth rank first, so they read like a diagram.
+var (
+	pawnTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	knightTable = [64]int{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	}
+	bishopTable = [64]int{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	}
+	rookTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	}
+	queenTable = [64]int{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	}
+	kingMiddleTable = [64]int{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	}
+	kingEndTable = [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	}
+)
+
+const endgameMaterial = 1300
+
+func readPieceBoards(b *chess.Board) (pb pieceBoards) {
+	data, err := b.MarshalBinary()
+	if err != nil {
+		return
+	}
+	for i := 0; i < 12; i++ {
+		pb[i+1] = bits.Reverse64(binary.BigEndian.Uint64(data[i*8:]))
+	}
+	return
+}
+
+func pieceTable(pt chess.PieceType, endgame bool) *[64]int {
+	switch pt {
+	case chess.Pawn:
+		return &pawnTable
+	case chess.Knight:
+		return &knightTable
+	case chess.Bishop:
+		return &bishopTable
+	case chess.Rook:
+		return &rookTable
+	case chess.Queen:
+		return &queenTable
+	}
+	if endgame {
+		return &kingEndTable
+	}
+	return &kingMiddleTable
+}
+
+func tableIndex(sq int, c chess.Color) int {
+	if c == chess.White {
+		return (7-sq/8)*8 + sq%8
+	}
+	return sq
+}
+
+// Evaluate returns a static evaluation of pos in centipawns from the point
+// of view of the side to move.
+func Evaluate(pos *chess.Position) int {
+	return evaluate(readPieceBoards(pos.Board()), pos.Turn())
+}
+
+func evaluate(pb pieceBoards, turn chess.Color) int {
+	var material [3]int
+	for p := chess.WhiteQueen; p <= chess.BlackPawn; p++ {
+		if p.Type() != chess.Pawn {
+			material[p.Color()] += pieceValues[p.Type()] * bits.OnesCount64(pb[p])
+		}
+	}
+	endgame := material[chess.White] <= endgameMaterial && material[chess.Black] <= endgameMaterial
+
+	var score [3]int
+	for p := chess.WhiteKing; p <= chess.BlackPawn; p++ {
+		c := p.Color()
+		table := pieceTable(p.Type(), endgame)
+		for bb := pb[p]; bb != 0; bb &= bb - 1 {
+			sq := bits.TrailingZeros64(bb)
+			score[c] += pieceValues[p.Type()] + table[tableIndex(sq, c)]
+		}
+	}
+	if bits.OnesCount64(pb[chess.WhiteBishop]) >= 2 {
+		score[chess.White] += 30
+	}
+	if bits.OnesCount64(pb[chess.BlackBishop]) >= 2 {
+		score[chess.Black] += 30
+	}
+
+	return score[turn] - score[turn.Other()]
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+// Level is a playing strength. Limits caps every search made at this
+// level. Spread lets the engine pick any move scoring within that many
+// centipawns of the best one, and Blunder is the chance of ignoring the
+// search and playing an arbitrary move that does not allow mate in one.
+type Level struct {
+	Name    string
+	Elo     int
+	Limits  Limits
+	Spread  int
+	Blunder float64
+}
+
+var Levels = []Level{
+	{Name: "Level 1", Elo: 400, Limits: Limits{Depth: 1}, Spread: 200, Blunder: 0.3},
+	{Name: "Level 2", Elo: 600, Limits: Limits{Depth: 1}, Spread: 120, Blunder: 0.2},
+	{Name: "Level 3", Elo: 800, Limits: Limits{Depth: 2}, Spread: 80, Blunder: 0.12},
+	{Name: "Level 4", Elo: 1000, Limits: Limits{Depth: 2, Nodes: 5000}, Spread: 50, Blunder: 0.08},
+	{Name: "Level 5", Elo: 1200, Limits: Limits{Depth: 3, Nodes: 20000}, Spread: 30, Blunder: 0.04},
+	{Name: "Level 6", Elo: 1400, Limits: Limits{Depth: 4, Nodes: 60000}, Spread: 15, Blunder: 0.02},
+	{Name: "Level 7", Elo: 1600, Limits: Limits{Depth: 5, Nodes: 200000}, Spread: 5},
+	{Name: "Level 8", Elo: 2000},
+}
+
+// MaxLevel is the full strength of the engine.
+var MaxLevel = Levels[len(Levels)-1]
+
+// Merge returns the tighter of each bound in l and o.
+func (l Limits) Merge(o Limits) Limits {
+	if o.Depth > 0 && (l.Depth == 0 || o.Depth < l.Depth) {
+		l.Depth = o.Depth
+	}
+	if o.Nodes > 0 && (l.Nodes == 0 || o.Nodes < l.Nodes) {
+		l.Nodes = o.Nodes
+	}
+	if o.MoveTime > 0 && (l.MoveTime == 0 || o.MoveTime < l.MoveTime) {
+		l.MoveTime = o.MoveTime
+	}
+	return l
+}
@@ -0,0 +1,43 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitsMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		l    Limits
+		o    Limits
+		want Limits
+	}{
+		{"both empty", Limits{}, Limits{}, Limits{}},
+		{"fills unset", Limits{}, Limits{Depth: 3, Nodes: 100, MoveTime: time.Second}, Limits{Depth: 3, Nodes: 100, MoveTime: time.Second}},
+		{"keeps set", Limits{Depth: 3, Nodes: 100, MoveTime: time.Second}, Limits{}, Limits{Depth: 3, Nodes: 100, MoveTime: time.Second}},
+		{"takes tighter", Limits{Depth: 5, Nodes: 50, MoveTime: time.Second}, Limits{Depth: 2, Nodes: 500, MoveTime: time.Millisecond}, Limits{Depth: 2, Nodes: 50, MoveTime: time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.l.Merge(tt.o); got != tt.want {
+				t.Errorf("%+v.Merge(%+v) = %+v, want %+v", tt.l, tt.o, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const infinity = MateScore + 1
+
+// Searcher is the built-in alpha-beta engine. It searches with iterative
+// deepening, a quiescence search over captures and a transposition table
+// that is kept between moves of the same game.
+type Searcher struct {
+	// Info, when set, is called after every completed iteration with the
+	// best line so far and the time spent searching.
+	Info func(Result, time.Duration)
+
+	mu    sync.Mutex
+	tt    *table
+	level Level
+	rand  *rand.Rand
+
+	ctx      context.Context
+	limits   Limits
+	deadline time.Time
+	nodes    int64
+	stopped  bool
+	history  []uint64
+}
+
+type scoredMove struct {
+	move  *chess.Move
+	score int
+}
+
+func NewSearcher() *Searcher {
+	return &Searcher{
+		tt:    newTable(),
+		level: MaxLevel,
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (s *Searcher) SetLevel(level Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.level = level
+}
+
+// NewGame forgets everything learned in previous searches, whose
+// positions will not come up again.
+func (s *Searcher) NewGame() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tt.clear()
+}
+
+func (s *Searcher) Close() error {
+	return nil
+}
+
+func (s *Searcher) Search(ctx context.Context, game *chess.Game, limits Limits) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos := game.Position()
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		return Result{}, ErrNoMoves
+	}
+
+	limits = limits.Merge(s.level.Limits)
+	if limits == (Limits{}) {
+		limits.MoveTime = DefaultMoveTime
+	}
+	start := time.Now()
+	s.ctx = ctx
+	s.limits = limits
+	s.nodes = 0
+	s.stopped = false
+	s.deadline = time.Time{}
+	if limits.MoveTime > 0 {
+		s.deadline = start.Add(limits.MoveTime)
+	}
+
+	positions := game.Positions()
+	s.history = s.history[:0]
+	for _, p := range positions[:len(positions)-1] {
+		s.history = append(s.history, hashPosition(p, readPieceBoards(p.Board())))
+	}
+
+	maxDepth := MaxPly - 1
+	if limits.Depth > 0 && limits.Depth < maxDepth {
+		maxDepth = limits.Depth
+	}
+
+	result := Result{Move: moves[0]}
+	var scored []scoredMove
+	for depth := 1; depth <= maxDepth; depth++ {
+		move, score, rootScores := s.searchRoot(pos, moves, depth)
+		if move == nil {
+			break
+		}
+		result.Move = move
+		result.Score = score
+		result.Depth = depth
+		result.PV = s.principalVariation(pos, depth)
+		result.Nodes = s.nodes
+		scored = rootScores
+		if s.Info != nil {
+			s.Info(result, time.Since(start))
+		}
+		if s.stopped {
+			break
+		}
+		if mate, _ := IsMate(score); mate {
+			break
+		}
+		// An iteration costs several times the one before it, so there
+		// is little point starting one we are unlikely to finish.
+		if !s.deadline.IsZero() && time.Since(start) > limits.MoveTime/2 {
+			break
+		}
+	}
+	result.Nodes = s.nodes
+
+	if choice := s.choose(pos, scored, result.Score); choice.move != nil && choice.move != result.Move {
+		result.Move = choice.move
+		result.Score = choice.score
+		result.PV = []*chess.Move{choice.move}
+	}
+
+	return result, nil
+}
+
+// searchRoot returns the best move at depth along with the scores of every
+// root move it finished searching. When the level has a spread, moves are
+// searched with a window wide enough to score them exactly within it.
+func (s *Searcher) searchRoot(pos *chess.Position, moves []*chess.Move, depth int) (*chess.Move, int, []scoredMove) {
+	pb := readPieceBoards(pos.Board())
+	key := hashPosition(pos, pb)
+	e, _ := s.tt.probe(key)
+	s.orderMoves(moves, pb, e)
+
+	var best *chess.Move
+	var scored []scoredMove
+	alpha := -infinity
+	s.history = append(s.history, key)
+	for _, m := range moves {
+		floor := alpha
+		if s.level.Spread > 0 && alpha > -infinity {
+			floor = alpha - s.level.Spread
+		}
+		score := -s.negamax(pos.Update(m), depth-1, 1, -infinity, -floor)
+		if s.stopped {
+			break
+		}
+		scored = append(scored, scoredMove{move: m, score: score})
+		if score > alpha {
+			alpha = score
+			best = m
+		}
+	}
+	s.history = s.history[:len(s.history)-1]
+
+	if best != nil {
+		s.tt.store(key, depth, toTableScore(alpha, 0), boundExact, best)
+	}
+	return best, alpha, scored
+}
+
+// choose applies the level's spread and blunder chance to the root moves
+// of pos. Root scores below the best are only upper bounds, and too
+// shallow to see mate at the lowest levels, so blunders are checked for an
+// answering mate directly.
+func (s *Searcher) choose(pos *chess.Position, scored []scoredMove, best int) scoredMove {
+	var candidates []scoredMove
+	if s.level.Blunder > 0 && s.rand.Float64() < s.level.Blunder {
+		for _, sm := range scored {
+			if !allowsMate(pos.Update(sm.move)) {
+				candidates = append(candidates, sm)
+			}
+		}
+	} else if s.level.Spread > 0 {
+		for _, sm := range scored {
+			if sm.score >= best-s.level.Spread {
+				candidates = append(candidates, sm)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return scoredMove{}
+	}
+	return candidates[s.rand.Intn(len(candidates))]
+}
+
+func (s *Searcher) negamax(pos *chess.Position, depth, ply, alpha, beta int) int {
+	if s.stop() {
+		return 0
+	}
+
+	pb := readPieceBoards(pos.Board())
+	key := hashPosition(pos, pb)
+	if s.repeated(key) || pos.HalfMoveClock() >= 100 {
+		return 0
+	}
+	if depth <= 0 || ply >= MaxPly-1 {
+		return s.quiesce(pos, pb, ply, alpha, beta)
+	}
+	s.nodes++
+
+	e, ok := s.tt.probe(key)
+	if ok && int(e.depth) >= depth {
+		score := fromTableScore(int(e.score), ply)
+		switch {
+		case e.bound == boundExact:
+			return score
+		case e.bound == boundLower && score >= beta:
+			return score
+		case e.bound == boundUpper && score <= alpha:
+			return score
+		}
+	}
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -MateScore + ply
+		}
+		return 0
+	}
+	s.orderMoves(moves, pb, e)
+
+	origAlpha := alpha
+	best := -infinity
+	var bestMove *chess.Move
+	s.history = append(s.history, key)
+	for _, m := range moves {
+		score := -s.negamax(pos.Update(m), depth-1, ply+1, -beta, -alpha)
+		if s.stopped {
+			s.history = s.history[:len(s.history)-1]
+			return 0
+		}
+		if score > best {
+			best = score
+			bestMove = m
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	s.history = s.history[:len(s.history)-1]
+
+	b := boundExact
+	if best <= origAlpha {
+		b = boundUpper
+	} else if best >= beta {
+		b = boundLower
+	}
+	s.tt.store(key, depth, toTableScore(best, ply), b, bestMove)
+
+	return best
+}
+
+func (s *Searcher) quiesce(pos *chess.Position, pb pieceBoards, ply, alpha, beta int) int {
+	s.nodes++
+	if s.stop() {
+		return 0
+	}
+
+	standPat := evaluate(pb, pos.Turn())
+	if standPat >= beta || ply >= MaxPly-1 {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	var captures []*chess.Move
+	for _, m := range pos.ValidMoves() {
+		if isCapture(m) || m.Promo() == chess.Queen {
+			captures = append(captures, m)
+		}
+	}
+	s.orderMoves(captures, pb, entry{})
+
+	for _, m := range captures {
+		child := pos.Update(m)
+		score := -s.quiesce(child, readPieceBoards(child.Board()), ply+1, -beta, -alpha)
+		if s.stopped {
+			return 0
+		}
+		if score >= beta {
+			return score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	return alpha
+}
+
+func (s *Searcher) stop() bool {
+	if s.stopped {
+		return true
+	}
+	if s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes {
+		s.stopped = true
+	} else if s.nodes&1023 == 0 {
+		select {
+		case <-s.ctx.Done():
+			s.stopped = true
+		default:
+		}
+		if !s.deadline.IsZero() && time.Now().After(s.deadline) {
+			s.stopped = true
+		}
+	}
+	return s.stopped
+}
+
+func (s *Searcher) repeated(key uint64) bool {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i] == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Searcher) principalVariation(pos *chess.Position, depth int) []*chess.Move {
+	var pv []*chess.Move
+	for i := 0; i < depth; i++ {
+		e, ok := s.tt.probe(hashPosition(pos, readPieceBoards(pos.Board())))
+		if !ok {
+			break
+		}
+		var next *chess.Move
+		for _, m := range pos.ValidMoves() {
+			if e.matches(m) {
+				next = m
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		pv = append(pv, next)
+		pos = pos.Update(next)
+	}
+	return pv
+}
+
+// allowsMate reports whether the side to move in pos can checkmate at once.
+func allowsMate(pos *chess.Position) bool {
+	for _, m := range pos.ValidMoves() {
+		if m.HasTag(chess.Check) && pos.Update(m).Status() == chess.Checkmate {
+			return true
+		}
+	}
+	return false
+}
+
+func isCapture(m *chess.Move) bool {
+	return m.HasTag(chess.Capture) || m.HasTag(chess.EnPassant)
+}
+
+// orderMoves sorts moves so that the hash move comes first, followed by
+// promotions and captures ordered most valuable victim, least valuable
+// attacker first.
+func (s *Searcher) orderMoves(moves []*chess.Move, pb pieceBoards, e entry) {
+	scores := make(map[*chess.Move]int, len(moves))
+	for _, m := range moves {
+		score := 0
+		switch {
+		case e.matches(m):
+			score = 1 << 20
+		case m.Promo() != chess.NoPieceType:
+			score = 1<<16 + pieceValues[m.Promo()]
+		case m.HasTag(chess.EnPassant):
+			score = 1<<15 + 10*pieceValues[chess.Pawn] - pieceValues[chess.Pawn]/10
+		case m.HasTag(chess.Capture):
+			victim := pb.piece(m.S2()).Type()
+			attacker := pb.piece(m.S1()).Type()
+			score = 1<<15 + 10*pieceValues[victim] - pieceValues[attacker]/10
+		case m.HasTag(chess.Check):
+			score = 1 << 10
+		}
+		scores[m] = score
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return scores[moves[i]] > scores[moves[j]]
+	})
+}
+
+func (pb *pieceBoards) piece(sq chess.Square) chess.Piece {
+	for p := chess.WhiteKing; p <= chess.BlackPawn; p++ {
+		if pb[p]&(1<<uint(sq)) != 0 {
+			return p
+		}
+	}
+	return chess.NoPiece
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+		mate  int
+	}{
+		{"mate in 1", "6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1", []string{"a1a8"}, 1},
+		{"mate in 2", "k7/8/2K5/8/8/8/8/7R w - - 0 1", []string{"c6b6", "c6c7"}, 2},
+		{"hanging queen", "4k3/8/8/3q4/8/8/8/3RK3 w - - 0 1", []string{"d1d5"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt, err := chess.FEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewSearcher()
+			s.SetLevel(MaxLevel)
+			result, err := s.Search(context.Background(), chess.NewGame(opt), Limits{Depth: 4})
+			if err != nil {
+				t.Fatal(err)
+			}
+			found := false
+			for _, m := range tt.moves {
+				found = found || result.Move.String() == m
+			}
+			if !found {
+				t.Errorf("best move = %s, want one of %v", result.Move, tt.moves)
+			}
+			if mate, n := IsMate(result.Score); tt.mate != 0 && (!mate || n != tt.mate) {
+				t.Errorf("score %d is not mate in %d", result.Score, tt.mate)
+			}
+		})
+	}
+}
+
+func TestSearchNoMoves(t *testing.T) {
+	opt, _ := chess.FEN("R5k1/5ppp/8/8/8/8/8/6K1 b - - 0 1")
+	if _, err := NewSearcher().Search(context.Background(), chess.NewGame(opt), Limits{Depth: 1}); err != ErrNoMoves {
+		t.Errorf("Search on a mated position: err = %v, want %v", err, ErrNoMoves)
+	}
+}
+
+func TestBlunderAvoidsMate(t *testing.T) {
+	// Every black move but the king stepping out or a pawn making room
+	// allows Ra8#.
+	opt, _ := chess.FEN("6k1/5ppp/8/8/8/8/8/R5K1 b - - 0 1")
+	safe := map[string]bool{"g8f8": true, "h7h6": true, "h7h5": true, "g7g6": true, "g7g5": true, "f7f6": true, "f7f5": true}
+	s := NewSearcher()
+	s.SetLevel(Level{Limits: Limits{Depth: 1}, Blunder: 1})
+	for i := 0; i < 20; i++ {
+		result, err := s.Search(context.Background(), chess.NewGame(opt), Limits{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !safe[result.Move.String()] {
+			t.Fatalf("blunder %s allows mate in one", result.Move)
+		}
+	}
+}
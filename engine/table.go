@@ -0,0 +1,160 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"math/bits"
+	"math/rand"
+
+	"github.com/notnil/chess"
+)
+
+type bound uint8
+
+const (
+	boundNone bound = iota
+	boundExact
+	boundLower
+	boundUpper
+)
+
+const tableSize = 1 << 18
+
+var (
+	zobristPieces    [13][64]uint64
+	zobristBlack     uint64
+	zobristCastle    [4]uint64
+	zobristEnPassant [8]uint64
+)
+
+func init() {
+	r := rand.New(rand.NewSource(0x62756262))
+	for p := range zobristPieces {
+		for sq := range zobristPieces[p] {
+			zobristPieces[p][sq] = r.Uint64()
+		}
+	}
+	zobristBlack = r.Uint64()
+	for i := range zobristCastle {
+		zobristCastle[i] = r.Uint64()
+	}
+	for i := range zobristEnPassant {
+		zobristEnPassant[i] = r.Uint64()
+	}
+}
+
+func hashPosition(pos *chess.Position, pb pieceBoards) uint64 {
+	var h uint64
+	for p := chess.WhiteKing; p <= chess.BlackPawn; p++ {
+		for bb := pb[p]; bb != 0; bb &= bb - 1 {
+			h ^= zobristPieces[p][bits.TrailingZeros64(bb)]
+		}
+	}
+	if pos.Turn() == chess.Black {
+		h ^= zobristBlack
+	}
+	cr := pos.CastleRights()
+	for i, c := range []chess.Color{chess.White, chess.Black} {
+		if cr.CanCastle(c, chess.KingSide) {
+			h ^= zobristCastle[i*2]
+		}
+		if cr.CanCastle(c, chess.QueenSide) {
+			h ^= zobristCastle[i*2+1]
+		}
+	}
+	if ep := pos.EnPassantSquare(); ep != chess.NoSquare {
+		h ^= zobristEnPassant[ep.File()]
+	}
+	return h
+}
+
+type entry struct {
+	key   uint64
+	s1    chess.Square
+	s2    chess.Square
+	promo chess.PieceType
+	depth int8
+	bound bound
+	score int32
+}
+
+// table is a fixed size, always-replace transposition table.
+type table struct {
+	entries []entry
+}
+
+func newTable() *table {
+	return &table{entries: make([]entry, tableSize)}
+}
+
+func (t *table) probe(key uint64) (entry, bool) {
+	e := t.entries[key%tableSize]
+	if e.bound == boundNone || e.key != key {
+		// Another position's move is no hash move for this one.
+		return entry{s1: chess.NoSquare, s2: chess.NoSquare}, false
+	}
+	return e, true
+}
+
+func (t *table) store(key uint64, depth int, score int, b bound, m *chess.Move) {
+	e := &t.entries[key%tableSize]
+	same := e.key == key && e.bound != boundNone
+	if same && int(e.depth) > depth && b != boundExact {
+		return
+	}
+	if m != nil {
+		e.s1, e.s2, e.promo = m.S1(), m.S2(), m.Promo()
+	} else if !same {
+		e.s1, e.s2, e.promo = chess.NoSquare, chess.NoSquare, chess.NoPieceType
+	}
+	e.key = key
+	e.depth = int8(depth)
+	e.bound = b
+	e.score = int32(score)
+}
+
+func (t *table) clear() {
+	for i := range t.entries {
+		t.entries[i] = entry{}
+	}
+}
+
+func (e entry) matches(m *chess.Move) bool {
+	return e.s1 != chess.NoSquare && e.s1 == m.S1() && e.s2 == m.S2() && e.promo == m.Promo()
+}
+
+// toTableScore and fromTableScore make mate scores relative to the node
+// they are stored at rather than the root.
+func toTableScore(score, ply int) int {
+	if score > MateScore-MaxPly {
+		return score + ply
+	}
+	if score < -MateScore+MaxPly {
+		return score - ply
+	}
+	return score
+}
+
+func fromTableScore(score, ply int) int {
+	if score > MateScore-MaxPly {
+		return score - ply
+	}
+	if score < -MateScore+MaxPly {
+		return score + ply
+	}
+	return score
+}
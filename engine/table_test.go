@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func mustPosition(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+	opt, err := chess.FEN(fen)
+	if err != nil {
+		t.Fatalf("FEN(%q): %v", fen, err)
+	}
+	return chess.NewGame(opt).Position()
+}
+
+func TestReadPieceBoards(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/8/8/8/8/8/8/K6k b - - 0 1",
+	}
+	for _, fen := range fens {
+		b := mustPosition(t, fen).Board()
+		pb := readPieceBoards(b)
+		squares := b.SquareMap()
+		for sq := chess.A1; sq <= chess.H8; sq++ {
+			if got, want := pb.piece(sq), squares[sq]; got != want {
+				t.Errorf("%s: piece on %s = %v, want %v", fen, sq, got, want)
+			}
+		}
+	}
+}
+
+func TestHashPosition(t *testing.T) {
+	hash := func(fen string) uint64 {
+		pos := mustPosition(t, fen)
+		return hashPosition(pos, readPieceBoards(pos.Board()))
+	}
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{"side to move", "4k3/8/8/8/8/8/8/4K3 w - - 0 1", "4k3/8/8/8/8/8/8/4K3 b - - 0 1"},
+		{"castling", "r3k3/8/8/8/8/8/8/4K3 b q - 0 1", "r3k3/8/8/8/8/8/8/4K3 b - - 0 1"},
+		{"en passant", "4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1", "4k3/8/8/8/4P3/8/8/4K3 b - - 0 1"},
+		{"piece", "4k3/8/8/8/8/8/8/4K2R w - - 0 1", "4k3/8/8/8/8/8/8/4K2B w - - 0 1"},
+	}
+	for _, tt := range tests {
+		if hash(tt.a) == hash(tt.b) {
+			t.Errorf("%s: %q and %q hash the same", tt.name, tt.a, tt.b)
+		}
+	}
+	if a, b := hash("4k3/8/8/8/8/8/8/4K3 w - - 0 1"), hash("4k3/8/8/8/8/8/8/4K3 w - - 12 40"); a != b {
+		t.Errorf("move counters change the hash: %x != %x", a, b)
+	}
+}
+
+func TestTableProbe(t *testing.T) {
+	tt := newTable()
+	pos := mustPosition(t, "4k3/8/8/8/8/8/8/4K2R w - - 0 1")
+	var move *chess.Move
+	for _, m := range pos.ValidMoves() {
+		if m.String() == "h1h8" {
+			move = m
+		}
+	}
+
+	key := uint64(12345)
+	tt.store(key, 3, 50, boundExact, move)
+	if e, ok := tt.probe(key); !ok || !e.matches(move) {
+		t.Errorf("probe(%d) = %+v, %v, want the stored entry", key, e, ok)
+	}
+
+	// A position sharing the slot gets neither the score nor the move.
+	other := key + tableSize
+	if e, ok := tt.probe(other); ok || e.matches(move) {
+		t.Errorf("probe(%d) = %+v, %v, want a miss", other, e, ok)
+	}
+	if e, ok := newTable().probe(key); ok || e.matches(move) {
+		t.Errorf("probe of an empty table = %+v, %v", e, ok)
+	}
+}
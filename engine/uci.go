@@ -0,0 +1,451 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	uciStartupTimeout  = 10 * time.Second
+	uciShutdownTimeout = 2 * time.Second
+)
+
+var ErrEngineExited = errors.New("engine: uci engine exited")
+
+// UCI drives an external engine process over the Universal Chess Interface.
+type UCI struct {
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	lines   chan string
+	name    string
+	options map[string]uciOption
+
+	// Settings are queued and sent before the next search rather than
+	// made to wait for the engine, which may be busy stopping.
+	queueMu sync.Mutex
+	queue   []string
+	level   Level
+}
+
+type uciOption struct {
+	typ string
+	min int
+	max int
+}
+
+// NewUCI starts the engine at path and waits for it to complete the UCI
+// handshake.
+func NewUCI(path string, args ...string) (*UCI, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("engine: starting %s: %w", path, err)
+	}
+
+	u := &UCI{
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 64),
+		name:    path,
+		options: map[string]uciOption{},
+		level:   MaxLevel,
+	}
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			u.lines <- scanner.Text()
+		}
+		close(u.lines)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), uciStartupTimeout)
+	defer cancel()
+	if err := u.handshake(ctx); err != nil {
+		u.kill()
+		go cmd.Wait()
+		return nil, fmt.Errorf("engine: %s did not complete the uci handshake: %w", path, err)
+	}
+	return u, nil
+}
+
+// Name returns the name the engine reported for itself.
+func (u *UCI) Name() string {
+	return u.name
+}
+
+func (u *UCI) handshake(ctx context.Context) error {
+	if err := u.send("uci"); err != nil {
+		return err
+	}
+	for {
+		line, err := u.readLine(ctx)
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "uciok":
+			return u.waitReady(ctx)
+		case fields[0] == "id" && len(fields) > 2 && fields[1] == "name":
+			u.name = strings.Join(fields[2:], " ")
+		case fields[0] == "option":
+			name, opt := parseUCIOption(fields[1:])
+			u.options[name] = opt
+		}
+	}
+}
+
+func (u *UCI) waitReady(ctx context.Context) error {
+	if err := u.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := u.readLine(ctx)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "readyok" {
+			return nil
+		}
+	}
+}
+
+func parseUCIOption(fields []string) (string, uciOption) {
+	var name []string
+	var opt uciOption
+	key := ""
+	for _, f := range fields {
+		switch f {
+		case "name", "type", "default", "min", "max", "var":
+			key = f
+			continue
+		}
+		switch key {
+		case "name":
+			name = append(name, f)
+		case "type":
+			opt.typ = f
+		case "min":
+			opt.min, _ = strconv.Atoi(f)
+		case "max":
+			opt.max, _ = strconv.Atoi(f)
+		}
+	}
+	return strings.Join(name, " "), opt
+}
+
+func (u *UCI) send(command string) error {
+	_, err := fmt.Fprintln(u.stdin, command)
+	return err
+}
+
+func (u *UCI) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-u.lines:
+		if !ok {
+			return "", ErrEngineExited
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// SetLevel asks the engine to limit its strength to the level's Elo when
+// it supports UCI_LimitStrength. The level's search limits are applied to
+// every search either way.
+func (u *UCI) SetLevel(level Level) {
+	u.queueMu.Lock()
+	defer u.queueMu.Unlock()
+	u.level = level
+
+	if _, ok := u.options["UCI_LimitStrength"]; !ok {
+		return
+	}
+	elo, ok := u.options["UCI_Elo"]
+	if !ok {
+		return
+	}
+	if level.Elo == MaxLevel.Elo {
+		u.queue = append(u.queue, "setoption name UCI_LimitStrength value false")
+		return
+	}
+	value := level.Elo
+	if value < elo.min {
+		value = elo.min
+	}
+	if elo.max > 0 && value > elo.max {
+		value = elo.max
+	}
+	u.queue = append(u.queue,
+		"setoption name UCI_LimitStrength value true",
+		fmt.Sprintf("setoption name UCI_Elo value %d", value),
+	)
+}
+
+// NewGame tells the engine that the next search is from a different game.
+func (u *UCI) NewGame() {
+	u.queueMu.Lock()
+	defer u.queueMu.Unlock()
+	u.queue = append(u.queue, "ucinewgame")
+}
+
+// sendQueued sends the queued settings and waits for the engine to be
+// ready for a search. It returns the level to search at.
+func (u *UCI) sendQueued(ctx context.Context) (Level, error) {
+	u.queueMu.Lock()
+	queue, level := u.queue, u.level
+	u.queue = nil
+	u.queueMu.Unlock()
+
+	if len(queue) == 0 {
+		return level, nil
+	}
+	for _, command := range queue {
+		if err := u.send(command); err != nil {
+			return level, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, uciStartupTimeout)
+	defer cancel()
+	return level, u.waitReady(ctx)
+}
+
+func (u *UCI) Search(ctx context.Context, game *chess.Game, limits Limits) (Result, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	pos := game.Position()
+	if len(pos.ValidMoves()) == 0 {
+		return Result{}, ErrNoMoves
+	}
+
+	level, err := u.sendQueued(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	limits = limits.Merge(level.Limits)
+	if limits == (Limits{}) {
+		limits.MoveTime = DefaultMoveTime
+	}
+
+	if err := u.send(PositionCommand(game)); err != nil {
+		return Result{}, err
+	}
+	if err := u.send(GoCommand(limits)); err != nil {
+		return Result{}, err
+	}
+
+	var result Result
+	readCtx := ctx
+	stopped := false
+	for {
+		line, err := u.readLine(readCtx)
+		if err != nil && !stopped && ctx.Err() != nil {
+			// Engines must answer stop with a bestmove, which has to be
+			// consumed before the next search can start. One that does
+			// not is stuck, and is killed rather than waited on.
+			stopped = true
+			if err := u.send("stop"); err != nil {
+				return Result{}, err
+			}
+			var cancel context.CancelFunc
+			readCtx, cancel = context.WithTimeout(context.Background(), uciShutdownTimeout)
+			defer cancel()
+			continue
+		} else if err != nil && stopped {
+			u.kill()
+			return Result{}, fmt.Errorf("engine: %s did not stop: %w", u.name, err)
+		} else if err != nil {
+			return Result{}, err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "info":
+			parseInfo(pos, fields[1:], &result)
+		case "bestmove":
+			if len(fields) < 2 {
+				return Result{}, fmt.Errorf("engine: malformed bestmove %q", line)
+			}
+			move, err := decodeUCIMove(pos, fields[1])
+			if err != nil {
+				return Result{}, err
+			}
+			result.Move = move
+			if stopped {
+				return result, ctx.Err()
+			}
+			return result, nil
+		}
+	}
+}
+
+// PositionCommand returns the UCI position command for the current
+// position of game.
+func PositionCommand(game *chess.Game) string {
+	positions := game.Positions()
+	start := positions[0].String()
+	var cmd string
+	if start == chess.StartingPosition().String() {
+		cmd = "position startpos"
+	} else {
+		cmd = "position fen " + start
+	}
+	moves := game.Moves()
+	if len(moves) > 0 {
+		cmd += " moves"
+		for i, m := range moves {
+			cmd += " " + chess.UCINotation{}.Encode(positions[i], m)
+		}
+	}
+	return cmd
+}
+
+// GoCommand returns the UCI go command for limits.
+func GoCommand(limits Limits) string {
+	cmd := "go"
+	if limits.Depth > 0 {
+		cmd += fmt.Sprintf(" depth %d", limits.Depth)
+	}
+	if limits.Nodes > 0 {
+		cmd += fmt.Sprintf(" nodes %d", limits.Nodes)
+	}
+	if limits.MoveTime > 0 {
+		cmd += fmt.Sprintf(" movetime %d", limits.MoveTime.Milliseconds())
+	}
+	return cmd
+}
+
+// InfoLine formats a search result as a UCI info line.
+func InfoLine(r Result, elapsed time.Duration) string {
+	score := fmt.Sprintf("cp %d", r.Score)
+	if mate, n := IsMate(r.Score); mate {
+		score = fmt.Sprintf("mate %d", n)
+	}
+	line := fmt.Sprintf("info depth %d score %s nodes %d time %d", r.Depth, score, r.Nodes, elapsed.Milliseconds())
+	if ms := elapsed.Milliseconds(); ms > 0 {
+		line += fmt.Sprintf(" nps %d", r.Nodes*1000/ms)
+	}
+	if len(r.PV) > 0 {
+		line += " pv"
+		for _, m := range r.PV {
+			line += " " + m.String()
+		}
+	}
+	return line
+}
+
+func parseInfo(pos *chess.Position, fields []string, result *Result) {
+	for i := 0; i < len(fields); i++ {
+		next := func() string {
+			if i+1 < len(fields) {
+				i++
+				return fields[i]
+			}
+			return ""
+		}
+		switch fields[i] {
+		case "depth":
+			result.Depth, _ = strconv.Atoi(next())
+		case "nodes":
+			result.Nodes, _ = strconv.ParseInt(next(), 10, 64)
+		case "score":
+			kind := next()
+			n, _ := strconv.Atoi(next())
+			switch kind {
+			case "cp":
+				result.Score = n
+			case "mate":
+				if n > 0 {
+					result.Score = MateScore - (2*n - 1)
+				} else {
+					result.Score = -MateScore - 2*n
+				}
+			}
+		case "pv":
+			result.PV = nil
+			p := pos
+			for _, s := range fields[i+1:] {
+				m, err := decodeUCIMove(p, s)
+				if err != nil {
+					break
+				}
+				result.PV = append(result.PV, m)
+				p = p.Update(m)
+			}
+			return
+		}
+	}
+}
+
+// decodeUCIMove finds the legal move in pos written as s in UCI notation.
+func decodeUCIMove(pos *chess.Position, s string) (*chess.Move, error) {
+	for _, m := range pos.ValidMoves() {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("engine: illegal move %q for position %s", s, pos)
+}
+
+func (u *UCI) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.send("quit")
+	u.stdin.Close()
+	done := make(chan error, 1)
+	go func() { done <- u.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(uciShutdownTimeout):
+		u.kill()
+		return <-done
+	}
+}
+
+func (u *UCI) kill() {
+	if u.cmd.Process != nil {
+		u.cmd.Process.Kill()
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2023 Daniel Gerard Ramirez
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// The test binary doubles as a tiny UCI engine when started with
+// fakeEngineEnv set, logging every command it gets to that file.
+const fakeEngineEnv = "BUBBLE_CHESS_FAKE_UCI_LOG"
+
+func TestMain(m *testing.M) {
+	if log := os.Getenv(fakeEngineEnv); log != "" {
+		runFakeEngine(log)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeEngine answers go at once with e2e4, except for go movetime,
+// which it answers with d2d4 only once told to stop, and go nodes, which
+// it never answers.
+func runFakeEngine(log string) {
+	logFile, err := os.Create(log)
+	if err != nil {
+		os.Exit(1)
+	}
+	defer logFile.Close()
+
+	searching := false
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(logFile, line)
+		switch fields := strings.Fields(line); fields[0] {
+		case "uci":
+			fmt.Println("id name Fake Engine 1.0")
+			fmt.Println("option name Hash type spin default 16 min 1 max 1024")
+			fmt.Println("option name UCI_LimitStrength type check default false")
+			fmt.Println("option name UCI_Elo type spin default 1500 min 1000 max 2800")
+			fmt.Println("uciok")
+		case "isready":
+			fmt.Println("readyok")
+		case "go":
+			if strings.Contains(line, "nodes") {
+				continue
+			}
+			if strings.Contains(line, "movetime") {
+				searching = true
+				fmt.Println("info depth 1 score cp 10 nodes 20 pv d2d4")
+				continue
+			}
+			fmt.Println("info depth 3 score cp 25 nodes 300 pv e2e4 e7e5")
+			fmt.Println("info depth 4 score mate 2 nodes 4000 pv e2e4 e7e5 d1h5")
+			fmt.Println("bestmove e2e4 ponder e7e5")
+		case "stop":
+			if searching {
+				searching = false
+				fmt.Println("bestmove d2d4")
+			}
+		case "quit":
+			return
+		}
+	}
+}
+
+func startFakeEngine(t *testing.T) (*UCI, string) {
+	t.Helper()
+	log := t.TempDir() + "/commands"
+	t.Setenv(fakeEngineEnv, log)
+	u, err := NewUCI(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, log
+}
+
+// commands closes the engine and returns what it was sent.
+func commands(t *testing.T, u *UCI, log string) []string {
+	t.Helper()
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUCIHandshake(t *testing.T) {
+	u, log := startFakeEngine(t)
+	if got, want := u.Name(), "Fake Engine 1.0"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+	if elo := u.options["UCI_Elo"]; elo.typ != "spin" || elo.min != 1000 || elo.max != 2800 {
+		t.Errorf("UCI_Elo option = %+v", elo)
+	}
+
+	// Settings wait for the next search to be sent.
+	u.SetLevel(Levels[0])
+	u.NewGame()
+	if _, err := u.Search(context.Background(), chess.NewGame(), Limits{Depth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	got := commands(t, u, log)
+	want := []string{
+		"uci",
+		"isready",
+		"setoption name UCI_LimitStrength value true",
+		"setoption name UCI_Elo value 1000",
+		"ucinewgame",
+		"isready",
+		"position startpos",
+		"go depth 1",
+		"quit",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("engine was sent %q, want %q", got, want)
+	}
+}
+
+func TestUCISearch(t *testing.T) {
+	u, log := startFakeEngine(t)
+	game := chess.NewGame()
+	if err := game.MoveStr("e4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := game.MoveStr("c5"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := u.Search(context.Background(), chess.NewGame(), Limits{Depth: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Move.String() != "e2e4" || result.Depth != 4 || result.Nodes != 4000 || len(result.PV) != 3 {
+		t.Errorf("Search() = %+v", result)
+	}
+	if mate, n := IsMate(result.Score); !mate || n != 2 {
+		t.Errorf("score %d is not mate in 2", result.Score)
+	}
+
+	// Cancelling sends stop, and the bestmove that answers it must not be
+	// taken for the result of the next search.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	result, err = u.Search(ctx, game, Limits{MoveTime: time.Hour})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled Search() error = %v, want %v", err, context.Canceled)
+	}
+	if result.Move == nil || result.Move.String() != "d2d4" {
+		t.Errorf("cancelled Search() move = %v, want d2d4", result.Move)
+	}
+	result, err = u.Search(context.Background(), chess.NewGame(), Limits{Depth: 1})
+	if err != nil || result.Move.String() != "e2e4" {
+		t.Errorf("Search() after stop = %v, %v, want e2e4", result.Move, err)
+	}
+
+	got := commands(t, u, log)
+	for _, want := range []string{
+		"position startpos",
+		"go depth 4",
+		"position startpos moves e2e4 c7c5",
+		"go movetime 3600000",
+		"stop",
+		"go depth 1",
+	} {
+		if !contains(got, want) {
+			t.Errorf("engine was not sent %q, got %q", want, got)
+		}
+	}
+}
+
+func TestUCISearchStuckEngine(t *testing.T) {
+	u, _ := startFakeEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := u.Search(ctx, chess.NewGame(), Limits{Nodes: 1000}); err == nil {
+		t.Error("Search() of an engine that ignores stop did not fail")
+	}
+	if elapsed := time.Since(start); elapsed > uciShutdownTimeout+time.Second {
+		t.Errorf("Search() took %v to give up", elapsed)
+	}
+
+	// The engine is gone, and nothing else waits on it.
+	u.NewGame()
+	if _, err := u.Search(context.Background(), chess.NewGame(), Limits{Depth: 1}); err == nil {
+		t.Error("Search() after the engine was killed did not fail")
+	}
+	u.Close()
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		info  string
+		want  Result
+		mate  bool
+		moves int
+		pv    string
+	}{
+		{"depth 5 seldepth 8 score cp 35 nodes 1200 nps 5000 pv e2e4 e7e5", Result{Depth: 5, Score: 35, Nodes: 1200}, false, 0, "e2e4 e7e5"},
+		{"depth 2 score cp -120 lowerbound", Result{Depth: 2, Score: -120}, false, 0, ""},
+		{"depth 3 score mate 1 pv g1f3", Result{Depth: 3, Score: MateScore - 1}, true, 1, "g1f3"},
+		{"depth 6 score mate 3", Result{Depth: 6, Score: MateScore - 5}, true, 3, ""},
+		{"depth 4 score mate -2", Result{Depth: 4, Score: -MateScore + 4}, true, -2, ""},
+		{"depth 1 pv e2e4 e2e4", Result{Depth: 1}, false, 0, "e2e4"},
+	}
+	for _, tt := range tests {
+		var got Result
+		parseInfo(chess.StartingPosition(), strings.Fields(tt.info), &got)
+		var pv []string
+		for _, m := range got.PV {
+			pv = append(pv, m.String())
+		}
+		got.PV = nil
+		if got.Depth != tt.want.Depth || got.Score != tt.want.Score || got.Nodes != tt.want.Nodes || strings.Join(pv, " ") != tt.pv {
+			t.Errorf("parseInfo(%q) = %+v pv %v, want %+v pv %q", tt.info, got, pv, tt.want, tt.pv)
+		}
+		if mate, n := IsMate(got.Score); mate != tt.mate || n != tt.moves {
+			t.Errorf("parseInfo(%q): IsMate(%d) = %v, %d, want %v, %d", tt.info, got.Score, mate, n, tt.mate, tt.moves)
+		}
+	}
+}
+
+func TestInfoLineRoundTrip(t *testing.T) {
+	for _, score := range []int{0, 42, -300, MateScore - 1, MateScore - 4, -MateScore + 2, -MateScore + 6} {
+		line := InfoLine(Result{Depth: 7, Score: score, Nodes: 99}, time.Second)
+		var got Result
+		parseInfo(chess.StartingPosition(), strings.Fields(line)[1:], &got)
+		gotMate, gotN := IsMate(got.Score)
+		wantMate, wantN := IsMate(score)
+		if gotMate != wantMate || gotN != wantN || (!wantMate && got.Score != score) {
+			t.Errorf("score %d went out as %q and came back as %d", score, line, got.Score)
+		}
+	}
+}
+
+func TestDecodeUCIMove(t *testing.T) {
+	opt, _ := chess.FEN("4k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	pos := chess.NewGame(opt).Position()
+	tests := []struct {
+		move  string
+		valid bool
+	}{
+		{"a7a8q", true},
+		{"a7a8n", true},
+		{"e1d2", true},
+		{"a7a8", false},
+		{"e1e3", false},
+		{"(none)", false},
+	}
+	for _, tt := range tests {
+		m, err := decodeUCIMove(pos, tt.move)
+		if tt.valid && (err != nil || m.String() != tt.move) {
+			t.Errorf("decodeUCIMove(%q) = %v, %v", tt.move, m, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("decodeUCIMove(%q) = %v, want an error", tt.move, m)
+		}
+	}
+}